@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/url"
@@ -10,22 +12,60 @@ import (
 	"time"
 
 	"github.com/NETWAYS/check_logstash/internal/client"
-	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/check_logstash/internal/logstash"
 	checkhttpconfig "github.com/NETWAYS/go-check-network/http/config"
 )
 
 type Config struct {
-	BasicAuth string `env:"CHECK_LOGSTASH_BASICAUTH"`
-	Bearer    string `env:"CHECK_LOGSTASH_BEARER"`
-	CAFile    string `env:"CHECK_LOGSTASH_CA_FILE"`
-	CertFile  string `env:"CHECK_LOGSTASH_CERT_FILE"`
-	KeyFile   string `env:"CHECK_LOGSTASH_KEY_FILE"`
-	Hostname  string `env:"CHECK_LOGSTASH_HOSTNAME"`
-	Port      int
-	Info      bool
-	Insecure  bool
-	PReady    bool
-	Secure    bool
+	BasicAuth             string
+	Bearer                string
+	BearerFile            string
+	PasswordFile          string
+	CredentialsCommand    string
+	Netrc                 bool
+	NetrcFile             string
+	APIKey                string
+	APIKeyFile            string
+	Headers               []string
+	CAFile                string
+	CertFile              string
+	KeyFile               string
+	PKCS12File            string
+	PKCS12Password        string
+	PKCS12PasswordFile    string
+	TLSServerName         string
+	TLSMinVersion         string
+	TLSExpiryWarnDays     int
+	Hostname              string
+	URL                   string
+	UnixSocket            string
+	Resolve               []string
+	StateFile             string
+	ConfigFile            string
+	Profile               string
+	Port                  int
+	ExpectNodeName        string
+	ExpectNodeID          string
+	ExpectHost            string
+	IdentityMismatchState int
+	OnlyProblems          bool
+	Output                string
+	Icinga2URL            string
+	Icinga2User           string
+	Icinga2Host           string
+	Icinga2Service        string
+	Info                  bool
+	Insecure              bool
+	PReady                bool
+	Secure                bool
+	// Timeout of a single HTTP request, only used by long-running commands
+	Timeout time.Duration
+	// stateScope separates the state file samples of the checks of serve, which may run the same subcommand
+	stateScope string
+	// certNotAfter is the expiry of the TLS certificate of the last response of the Logstash API
+	certNotAfter time.Time
+	// client is reused for all requests of the config, so long-running commands keep their connections
+	client *client.Client
 }
 
 const Copyright = `
@@ -53,14 +93,29 @@ var (
 	cliConfig Config
 )
 
-func (c *Config) NewClient() *client.Client {
-	u := url.URL{
-		Scheme: "http",
-		Host:   c.Hostname + ":" + strconv.Itoa(c.Port),
+// verifyNodeIdentity compares the identity of the Logstash node
+// with the expected values, empty expectations are ignored.
+func (c *Config) verifyNodeIdentity(node logstash.Node) error {
+	if c.ExpectNodeName != "" && c.ExpectNodeName != node.Name {
+		return fmt.Errorf("expected node name %s, got %s", c.ExpectNodeName, node.Name)
 	}
 
-	if c.Secure {
-		u.Scheme = "https"
+	if c.ExpectNodeID != "" && c.ExpectNodeID != node.ID {
+		return fmt.Errorf("expected node id %s, got %s", c.ExpectNodeID, node.ID)
+	}
+
+	if c.ExpectHost != "" && c.ExpectHost != node.Host {
+		return fmt.Errorf("expected host %s, got %s", c.ExpectHost, node.Host)
+	}
+
+	return nil
+}
+
+// newTransport creates the transport with the TLS configuration,
+// which is shared by the Logstash API and the Icinga 2 API.
+func (c *Config) newTransport() (*http.Transport, error) {
+	if c.PKCS12File != "" && (c.CertFile != "" || c.KeyFile != "") {
+		return nil, errors.New("specify either --pkcs12-file or --cert-file and --key-file")
 	}
 
 	// Create TLS configuration for default RoundTripper
@@ -71,10 +126,15 @@ func (c *Config) NewClient() *client.Client {
 		CertFile:           c.CertFile,
 	})
 	if err != nil {
-		check.ExitError(err)
+		return nil, err
 	}
 
-	var rt http.RoundTripper = &http.Transport{
+	err = c.applyTLSOptions(tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
 			Timeout:   30 * time.Second,
@@ -82,26 +142,222 @@ func (c *Config) NewClient() *client.Client {
 		}).DialContext,
 		TLSHandshakeTimeout: 10 * time.Second,
 		TLSClientConfig:     tlsConfig,
+	}, nil
+}
+
+// parseResolve parses an address override in the form host:port:address like curl's --resolve,
+// IPv6 addresses are given in brackets, e.g. [::1]:9600:[fe80::1].
+func parseResolve(spec string) (string, string, error) {
+	var host, rest string
+
+	if strings.HasPrefix(spec, "[") {
+		h, r, ok := strings.Cut(spec[1:], "]:")
+		if !ok {
+			return "", "", fmt.Errorf("could not parse resolve %s, use host:port:address", spec)
+		}
+
+		host, rest = h, r
+	} else {
+		h, r, ok := strings.Cut(spec, ":")
+		if !ok {
+			return "", "", fmt.Errorf("could not parse resolve %s, use host:port:address", spec)
+		}
+
+		host, rest = h, r
 	}
 
-	// Using a Bearer Token for authentication
-	if c.Bearer != "" {
-		rt = checkhttpconfig.NewAuthorizationCredentialsRoundTripper("Bearer", c.Bearer, rt)
+	port, address, ok := strings.Cut(rest, ":")
+	if !ok || host == "" || port == "" {
+		return "", "", fmt.Errorf("could not parse resolve %s, use host:port:address", spec)
 	}
 
-	// Using a BasicAuth for authentication
-	if c.BasicAuth != "" {
-		s := strings.Split(c.BasicAuth, ":")
-		if len(s) != 2 {
-			check.ExitError(errors.New("specify the user name and password for server authentication <user:password>"))
+	if _, err := strconv.Atoi(port); err != nil {
+		return "", "", fmt.Errorf("could not parse resolve %s, invalid port %s", spec, port)
+	}
+
+	if net.ParseIP(trimBrackets(address)) == nil {
+		return "", "", fmt.Errorf("could not parse resolve %s, invalid address %s", spec, address)
+	}
+
+	return net.JoinHostPort(host, port), net.JoinHostPort(trimBrackets(address), port), nil
+}
+
+// newDialContext wraps the dial function of the transport to connect to the Unix domain socket
+// of --unix-socket, or to the addresses of --resolve instead of resolving the host.
+func (c *Config) newDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	resolve := map[string]string{}
+
+	for _, spec := range c.Resolve {
+		host, address, err := parseResolve(spec)
+		if err != nil {
+			return nil, err
 		}
 
-		var u = s[0]
+		resolve[host] = address
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if c.UnixSocket != "" {
+			return dial(ctx, "unix", c.UnixSocket)
+		}
+
+		if address, ok := resolve[addr]; ok {
+			addr = address
+		}
+
+		return dial(ctx, network, addr)
+	}, nil
+}
 
-		var p = s[1]
+// parseHeaders parses the headers of --header in the form "Name: value".
+func parseHeaders(headers []string) (http.Header, error) {
+	h := http.Header{}
 
-		rt = checkhttpconfig.NewBasicAuthRoundTripper(u, p, rt)
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("could not parse header %s, use 'Name: value'", header)
+		}
+
+		h.Add(name, strings.TrimSpace(value))
 	}
 
-	return client.NewClient(u.String(), rt)
+	return h, nil
+}
+
+// apiKey returns the API key of --api-key or the content of --api-key-file.
+func (c *Config) apiKey() (string, error) {
+	if c.APIKeyFile == "" {
+		return c.APIKey, nil
+	}
+
+	if c.APIKey != "" {
+		return "", errors.New("specify either --api-key or --api-key-file")
+	}
+
+	return readSecret(c.APIKeyFile)
+}
+
+// trimBrackets removes the brackets of an IPv6 address, e.g. [::1] given as --hostname.
+func trimBrackets(host string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+}
+
+// BaseURL returns the base URL of the Logstash API, either from --url
+// or from --hostname and --port, which may be an IPv6 address.
+func (c *Config) BaseURL() (*url.URL, error) {
+	if c.URL == "" {
+		u := &url.URL{
+			Scheme: "http",
+			Host:   net.JoinHostPort(trimBrackets(c.Hostname), strconv.Itoa(c.Port)),
+		}
+
+		if c.Secure {
+			u.Scheme = "https"
+		}
+
+		return u, nil
+	}
+
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s: %w", c.URL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid URL %s, use http or https", c.URL)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %s, specify the host", c.URL)
+	}
+
+	return u, nil
+}
+
+// targetHost returns the host of the Logstash API, e.g. for the labels of the metrics.
+func (c *Config) targetHost() string {
+	if c.URL != "" {
+		if u, err := c.BaseURL(); err == nil {
+			return u.Hostname()
+		}
+	}
+
+	return c.Hostname
+}
+
+func (c *Config) NewClient() (*client.Client, error) {
+	u, err := c.BaseURL()
+	if err != nil {
+		return nil, err
+	}
+
+	tr, err := c.newTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	// The name of the Logstash certificate might differ from the address
+	if c.TLSServerName != "" {
+		tr.TLSClientConfig.ServerName = c.TLSServerName
+	}
+
+	// Connecting via Unix domain socket or overridden addresses
+	if c.UnixSocket != "" || len(c.Resolve) > 0 {
+		dial, err := c.newDialContext(tr.DialContext)
+		if err != nil {
+			return nil, err
+		}
+
+		tr.DialContext = dial
+
+		// The socket replaces the connection to a proxy as well
+		if c.UnixSocket != "" {
+			tr.Proxy = nil
+		}
+	}
+
+	var rt http.RoundTripper = tr
+
+	user, password, bearer, err := c.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	// Using a Bearer Token for authentication
+	if bearer != "" {
+		rt = checkhttpconfig.NewAuthorizationCredentialsRoundTripper("Bearer", bearer, rt)
+	}
+
+	// Using an API key for authentication
+	key, err := c.apiKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		rt = checkhttpconfig.NewAuthorizationCredentialsRoundTripper("ApiKey", key, rt)
+	}
+
+	// Using a BasicAuth for authentication
+	if user != "" {
+		rt = checkhttpconfig.NewBasicAuthRoundTripper(user, password, rt)
+	}
+
+	// Custom headers are added last, so they take precedence over the authentication
+	if len(c.Headers) > 0 {
+		h, err := parseHeaders(c.Headers)
+		if err != nil {
+			return nil, err
+		}
+
+		rt = client.NewHeaderRoundTripper(h, rt)
+	}
+
+	cl := client.NewClient(u.String(), rt)
+	cl.Client.Timeout = c.Timeout
+
+	return cl, nil
 }
@@ -1,13 +1,175 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
 )
 
 func TestConfig(t *testing.T) {
-	c := cliConfig.NewClient()
+	c, err := cliConfig.NewClient()
+	if err != nil {
+		t.Error(err)
+	}
+
 	expected := "http://localhost:9600"
 	if c.URL != "http://localhost:9600" {
 		t.Error("\nActual: ", c.URL, "\nExpected: ", expected)
 	}
 }
+
+func TestVerifyNodeIdentity(t *testing.T) {
+	node := logstash.Node{ID: "4", Name: "test", Host: "foobar"}
+
+	c := Config{ExpectNodeName: "test", ExpectNodeID: "4", ExpectHost: "foobar"}
+	if err := c.verifyNodeIdentity(node); err != nil {
+		t.Error("\nActual: ", err, "\nExpected: ", nil)
+	}
+
+	c = Config{ExpectNodeName: "other"}
+	expected := "expected node name other, got test"
+
+	err := c.verifyNodeIdentity(node)
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+
+	c = Config{ExpectHost: "other"}
+	expected = "expected host other, got foobar"
+
+	err = c.verifyNodeIdentity(node)
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+}
+
+func TestConfigBaseURL(t *testing.T) {
+	tests := []struct {
+		config   Config
+		expected string
+	}{
+		{Config{Hostname: "localhost", Port: 9600}, "http://localhost:9600"},
+		{Config{Hostname: "::1", Port: 9600, Secure: true}, "https://[::1]:9600"},
+		{Config{Hostname: "[fe80::1]", Port: 9600}, "http://[fe80::1]:9600"},
+		{Config{Hostname: "localhost", Port: 9600, URL: "https://proxy/logstash-eu/"}, "https://proxy/logstash-eu/"},
+		{Config{URL: "http://[::1]:8080/logstash"}, "http://[::1]:8080/logstash"},
+	}
+
+	for _, test := range tests {
+		u, err := test.config.BaseURL()
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if u.String() != test.expected {
+			t.Error("\nActual: ", u.String(), "\nExpected: ", test.expected)
+		}
+	}
+
+	errors := map[string]string{
+		"proxy/logstash":  "invalid URL proxy/logstash, use http or https",
+		"ftp://proxy":     "invalid URL ftp://proxy, use http or https",
+		"https:///foo":    "invalid URL https:///foo, specify the host",
+		"http://[::1/foo": `invalid URL http://[::1/foo: parse "http://[::1/foo": missing ']' in host`,
+	}
+
+	for u, expected := range errors {
+		c := Config{URL: u}
+
+		_, err := c.BaseURL()
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	tests := map[string][2]string{
+		"logstash:9600:127.0.0.1":    {"logstash:9600", "127.0.0.1:9600"},
+		"logstash:9600:[::1]":        {"logstash:9600", "[::1]:9600"},
+		"[fe80::1]:9600:10.0.0.1":    {"[fe80::1]:9600", "10.0.0.1:9600"},
+		"logstash.example:443:::1":   {"logstash.example:443", "[::1]:443"},
+		"logstash.example:443:[::1]": {"logstash.example:443", "[::1]:443"},
+	}
+
+	for spec, expected := range tests {
+		host, address, err := parseResolve(spec)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if host != expected[0] || address != expected[1] {
+			t.Error("\nActual: ", host, address, "\nExpected: ", expected)
+		}
+	}
+
+	errors := map[string]string{
+		"logstash":             "could not parse resolve logstash, use host:port:address",
+		"logstash:9600":        "could not parse resolve logstash:9600, use host:port:address",
+		"logstash:foo:1.2.3.4": "could not parse resolve logstash:foo:1.2.3.4, invalid port foo",
+		"logstash:9600:foo":    "could not parse resolve logstash:9600:foo, invalid address foo",
+		"[::1:9600:1.2.3.4":    "could not parse resolve [::1:9600:1.2.3.4, use host:port:address",
+	}
+
+	for spec, expected := range errors {
+		_, _, err := parseResolve(spec)
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	h, err := parseHeaders([]string{"X-Tenant: eu", "X-Forwarded-For:a", "X-Forwarded-For: b:c", "X-Empty:"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.Get("X-Tenant") != "eu" {
+		t.Error("\nActual: ", h.Get("X-Tenant"), "\nExpected: ", "eu")
+	}
+
+	if v := h.Values("X-Forwarded-For"); len(v) != 2 || v[1] != "b:c" {
+		t.Error("\nActual: ", v, "\nExpected: ", []string{"a", "b:c"})
+	}
+
+	if v := h.Values("X-Empty"); len(v) != 1 || v[0] != "" {
+		t.Error("\nActual: ", v, "\nExpected: ", []string{""})
+	}
+
+	for _, header := range []string{"X-Tenant", ": eu", "X Tenant: eu"} {
+		_, err := parseHeaders([]string{header})
+
+		expected := "could not parse header " + header + ", use 'Name: value'"
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestConfigAPIKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "api-key")
+
+	err := os.WriteFile(file, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{APIKeyFile: file}
+
+	key, err := c.apiKey()
+	if err != nil || key != "secret" {
+		t.Error("\nActual: ", key, err, "\nExpected: ", "secret")
+	}
+
+	c.APIKey = "foo"
+
+	_, err = c.apiKey()
+	if err == nil {
+		t.Error("\nActual: ", err, "\nExpected: ", "specify either --api-key or --api-key-file")
+	}
+}
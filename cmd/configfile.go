@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// configFile is the file of --config, the profiles hold values for the flags.
+type configFile struct {
+	path     string
+	Profiles map[string]configProfile `yaml:"profiles"`
+}
+
+// configProfile holds the values of the global flags, e.g. the connection settings,
+// and the values of the subcommand flags by command, e.g. "pipeline flow".
+type configProfile struct {
+	Flags    map[string]yaml.Node            `yaml:",inline"`
+	Commands map[string]map[string]yaml.Node `yaml:"commands"`
+}
+
+// configValues returns the values of a flag in the configuration file,
+// a list is only allowed for flags that can be used multiple times.
+func configValues(f *pflag.Flag, n yaml.Node) ([]string, error) {
+	var values []string
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		values = append(values, n.Value)
+	case yaml.SequenceNode:
+		if _, ok := f.Value.(pflag.SliceValue); !ok {
+			return nil, fmt.Errorf("line %d: %s does not accept a list", n.Line, f.Name)
+		}
+
+		for _, item := range n.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: %s only accepts a list of values", item.Line, f.Name)
+			}
+
+			values = append(values, item.Value)
+		}
+	default:
+		return nil, fmt.Errorf("line %d: %s requires a value", n.Line, f.Name)
+	}
+
+	for _, v := range values {
+		var err error
+
+		switch f.Value.Type() {
+		case "bool":
+			_, err = strconv.ParseBool(v)
+		case "int":
+			_, err = strconv.Atoi(v)
+		case "float64":
+			_, err = strconv.ParseFloat(v, 64)
+		case "duration":
+			_, err = time.ParseDuration(v)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid %s value %q for %s", n.Line, f.Value.Type(), v, f.Name)
+		}
+	}
+
+	return values, nil
+}
+
+// configCommand returns the subcommand of a command section, e.g. "pipeline flow".
+func configCommand(root *cobra.Command, path string) (*cobra.Command, error) {
+	c, args, err := root.Find(strings.Fields(path))
+	if err != nil || c == root || len(args) > 0 {
+		return nil, fmt.Errorf("unknown command %s", path)
+	}
+
+	return c, nil
+}
+
+// configFlag returns the flag of a command, the global flags are only
+// allowed in the profile itself and not in the command sections.
+func configFlag(root, c *cobra.Command, name string) (*pflag.Flag, error) {
+	if c == root {
+		f := root.PersistentFlags().Lookup(name)
+		if f == nil || name == "config" || name == "profile" {
+			return nil, fmt.Errorf("unknown flag %s", name)
+		}
+
+		return f, nil
+	}
+
+	if root.PersistentFlags().Lookup(name) != nil {
+		return nil, fmt.Errorf("%s is a global flag, set it in the profile instead of the command", name)
+	}
+
+	f := c.Flags().Lookup(name)
+	if f == nil {
+		f = c.PersistentFlags().Lookup(name)
+	}
+
+	if f == nil {
+		f = c.InheritedFlags().Lookup(name)
+	}
+
+	if f == nil {
+		return nil, fmt.Errorf("unknown flag %s for command %s", name, c.Name())
+	}
+
+	return f, nil
+}
+
+// validate checks all profiles of the configuration file, so errors show up
+// regardless of the profile and subcommand in use.
+func (cf *configFile) validate(root *cobra.Command) error {
+	for name, p := range cf.Profiles {
+		for flag, n := range p.Flags {
+			f, err := configFlag(root, root, flag)
+			if err == nil {
+				_, err = configValues(f, n)
+			}
+
+			if err != nil {
+				return fmt.Errorf("invalid profile %s in %s: %w", name, cf.path, err)
+			}
+		}
+
+		for path, flags := range p.Commands {
+			c, err := configCommand(root, path)
+			if err != nil {
+				return fmt.Errorf("invalid profile %s in %s: %w", name, cf.path, err)
+			}
+
+			for flag, n := range flags {
+				f, err := configFlag(root, c, flag)
+				if err == nil {
+					_, err = configValues(f, n)
+				}
+
+				if err != nil {
+					return fmt.Errorf("invalid profile %s in %s: %w", name, cf.path, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadConfigFile reads and validates the configuration file.
+func loadConfigFile(path string, root *cobra.Command) (*configFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration file: %w", err)
+	}
+
+	cf := &configFile{path: path}
+
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	err = dec.Decode(cf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("could not parse configuration file %s: %w", path, err)
+	}
+
+	err = cf.validate(root)
+	if err != nil {
+		return nil, err
+	}
+
+	return cf, nil
+}
+
+// apply sets the flags of the command from the profile, flags set on the command line are
+// not overwritten. The section of a parent command applies to its subcommands as well,
+// e.g. the "pipeline" section applies to "pipeline flow".
+func (cf *configFile) apply(cmd *cobra.Command, profile string) error {
+	if profile == "" {
+		profile = "default"
+
+		if _, ok := cf.Profiles[profile]; !ok {
+			return nil
+		}
+	}
+
+	p, ok := cf.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(cf.Profiles))
+		for name := range cf.Profiles {
+			names = append(names, name)
+		}
+
+		slices.Sort(names)
+
+		return fmt.Errorf("unknown profile %s in %s, available profiles: %s", profile, cf.path, strings.Join(names, ", "))
+	}
+
+	root := cmd.Root()
+
+	// The sections of subcommands override the values of their parent commands
+	values := map[string]yaml.Node{}
+	maps.Copy(values, p.Flags)
+
+	var parents []*cobra.Command
+	for c := cmd; c != root; c = c.Parent() {
+		parents = append([]*cobra.Command{c}, parents...)
+	}
+
+	for _, c := range parents {
+		maps.Copy(values, p.Commands[strings.TrimPrefix(c.CommandPath(), root.Name()+" ")])
+	}
+
+	for name, n := range values {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+
+		vs, err := configValues(f, n)
+		if err != nil {
+			return fmt.Errorf("invalid profile %s in %s: %w", profile, cf.path, err)
+		}
+
+		for _, v := range vs {
+			err = cmd.Flags().Set(name, v)
+			if err != nil {
+				return fmt.Errorf("invalid profile %s in %s: line %d: %w", profile, cf.path, n.Line, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadProfile applies the profile of the configuration file to the command, if a file is given.
+func loadProfile(cmd *cobra.Command, path, profile string) error {
+	if path == "" {
+		if profile != "" {
+			return errors.New("specify the configuration file of the profile with --config")
+		}
+
+		return nil
+	}
+
+	cf, err := loadConfigFile(path, cmd.Root())
+	if err != nil {
+		return err
+	}
+
+	return cf.apply(cmd, profile)
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "check_logstash.yml")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+profiles:
+  prod:
+    hostname: logstash.example.com
+    secure: true
+    commands:
+      pipeline:
+        include: [ "main", "beats-*" ]
+      pipeline flow:
+        warning: 5
+        critical: 10
+`)
+
+	cf, err := loadConfigFile(path, rootCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cf.Profiles["prod"].Flags["hostname"].Value != "logstash.example.com" {
+		t.Error("\nActual: ", cf.Profiles["prod"].Flags["hostname"].Value, "\nExpected: ", "logstash.example.com")
+	}
+
+	tests := map[string]string{
+		"profiles:\n  prod:\n    foo: bar\n":                                               "invalid profile prod in %s: unknown flag foo",
+		"profiles:\n  prod:\n    port: abc\n":                                              `invalid profile prod in %s: line 3: invalid int value "abc" for port`,
+		"profiles:\n  prod:\n    port: [ 1, 2 ]\n":                                         "invalid profile prod in %s: line 3: port does not accept a list",
+		"profiles:\n  prod:\n    commands:\n      foo:\n        bar: 1\n":                  "invalid profile prod in %s: unknown command foo",
+		"profiles:\n  prod:\n    commands:\n      health:\n        port: 1\n":              "invalid profile prod in %s: port is a global flag, set it in the profile instead of the command",
+		"profiles:\n  prod:\n    commands:\n      pipeline flow:\n        stuck-runs: 1\n": "invalid profile prod in %s: unknown flag stuck-runs for command flow",
+		"profile:\n  prod:\n    port: 1\n":                                                 "could not parse configuration file %s: yaml: unmarshal errors:\n  line 1: field profile not found in type cmd.configFile",
+	}
+
+	for content, expected := range tests {
+		path := writeConfigFile(t, content)
+		expected = strings.ReplaceAll(expected, "%s", path)
+
+		_, err := loadConfigFile(path, rootCmd)
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestConfigFileProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"localhost","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"example":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":0,"in":0},"flow":{"queue_backpressure":{"current":7}}}}}`))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	path := writeConfigFile(t, `
+profiles:
+  default:
+    port: 1
+  prod:
+    port: `+u.Port()+`
+    commands:
+      pipeline flow:
+        warning: 5
+        critical: 10
+`)
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{"profile", []string{"--profile", "prod"}, "[WARNING] - queue_backpressure_example:7.00"},
+		{"profile-override", []string{"--profile", "prod", "--critical", "6"}, "[CRITICAL] - queue_backpressure_example:7.00"},
+		{"profile-default", []string{"--warning", "5", "--critical", "10"}, "[UNKNOWN] - Get \"http://localhost:1/"},
+		{"profile-unknown", []string{"--profile", "foo"}, "[UNKNOWN] - unknown profile foo in " + path + ", available profiles: default, prod"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := exec.Command("go", append([]string{"run", "../main.go", "pipeline", "flow", "--config", path}, test.args...)...)
+			out, _ := cmd.CombinedOutput()
+
+			actual := string(out)
+
+			if !strings.Contains(actual, test.expected) {
+				t.Error("\nActual: ", actual, "\nExpected: ", test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// readSecret reads a password or token from a file, surrounding whitespace is removed.
+func readSecret(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("could not read secret: %w", err)
+	}
+
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("secret file %s is empty", path)
+	}
+
+	return secret, nil
+}
+
+// credentialsCache keeps the output of each credentials command for the life of the process,
+// since a client is created for every request of discover and every poll of serve.
+var credentialsCache = struct {
+	sync.Mutex
+	secrets map[string]string
+}{secrets: map[string]string{}}
+
+// runCredentialsCommand runs the command of --credentials-command with the shell
+// and returns its output, e.g. of a password manager. The command is stopped after --timeout.
+func runCredentialsCommand(command string) (string, error) {
+	credentialsCache.Lock()
+	defer credentialsCache.Unlock()
+
+	if secret, ok := credentialsCache.secrets[command]; ok {
+		return secret, nil
+	}
+
+	timeout := time.Duration(Timeout) * time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Stderr = &stderr
+	// Do not wait for child processes of the shell that keep the output open
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("credentials command timed out after %s", timeout)
+		}
+
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("credentials command failed: %w: %s", err, msg)
+		}
+
+		return "", fmt.Errorf("credentials command failed: %w", err)
+	}
+
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", errors.New("credentials command returned no output")
+	}
+
+	credentialsCache.secrets[command] = secret
+
+	return secret, nil
+}
+
+// netrcTokens splits the content of a netrc file into tokens, without the bodies of macro definitions.
+func netrcTokens(data string) []string {
+	var (
+		tokens []string
+		macdef bool
+	)
+
+	for line := range strings.Lines(data) {
+		// Macro definitions end with an empty line
+		if macdef {
+			macdef = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+		tokens = append(tokens, fields...)
+
+		// The name of the macro is on the same line
+		macdef = len(fields) > 0 && (fields[0] == "macdef" || (len(fields) > 1 && fields[len(fields)-2] == "macdef"))
+	}
+
+	return tokens
+}
+
+// parseNetrc returns the login and password of the host from the content of a netrc file,
+// the default entry is used if there is no entry for the host.
+func parseNetrc(data, host string) (string, string, bool) {
+	var (
+		login, password string
+		found           bool
+	)
+
+	tokens := netrcTokens(data)
+
+	for i := 0; i < len(tokens); i++ {
+		var value string
+		if i+1 < len(tokens) {
+			value = tokens[i+1]
+		}
+
+		switch tokens[i] {
+		case "machine":
+			if found {
+				return login, password, true
+			}
+
+			found = value == host
+			i++
+		case "default":
+			if found {
+				return login, password, true
+			}
+
+			found = true
+		case "login":
+			if found {
+				login = value
+			}
+
+			i++
+		case "password":
+			if found {
+				password = value
+			}
+
+			i++
+		case "account", "macdef":
+			i++
+		}
+	}
+
+	return login, password, found
+}
+
+// netrcPath returns the file of --netrc-file, or with --netrc the file
+// of the NETRC environment variable or .netrc in the home directory.
+func (c *Config) netrcPath() (string, error) {
+	if c.NetrcFile != "" {
+		return c.NetrcFile, nil
+	}
+
+	if path := os.Getenv("NETRC"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find netrc file: %w", err)
+	}
+
+	return filepath.Join(home, ".netrc"), nil
+}
+
+// credentials returns the user name and password for Basic Auth and the Bearer Token.
+// The secrets are taken from the flags, from files, from the output of --credentials-command
+// or, if no other credentials are given, from the netrc file.
+func (c *Config) credentials() (string, string, string, error) {
+	var user, password string
+
+	bearer := c.Bearer
+
+	if c.BearerFile != "" {
+		if c.Bearer != "" {
+			return "", "", "", errors.New("specify either --bearer or --bearer-file")
+		}
+
+		secret, err := readSecret(c.BearerFile)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		bearer = secret
+	}
+
+	switch {
+	case c.BasicAuth != "":
+		var hasPassword bool
+
+		// Only the first colon separates the user name, the password may contain colons
+		user, password, hasPassword = strings.Cut(c.BasicAuth, ":")
+
+		sources := 0
+
+		for _, ok := range []bool{hasPassword, c.PasswordFile != "", c.CredentialsCommand != ""} {
+			if ok {
+				sources++
+			}
+		}
+
+		if sources > 1 {
+			return "", "", "", errors.New("specify the password either with --user, --password-file or --credentials-command")
+		}
+
+		if sources == 0 {
+			return "", "", "", errors.New("specify the user name and password for server authentication <user:password>")
+		}
+
+		var err error
+
+		if c.PasswordFile != "" {
+			password, err = readSecret(c.PasswordFile)
+		}
+
+		if c.CredentialsCommand != "" {
+			password, err = runCredentialsCommand(c.CredentialsCommand)
+		}
+
+		if err != nil {
+			return "", "", "", err
+		}
+	case c.PasswordFile != "":
+		return "", "", "", errors.New("specify the user name of --password-file with --user")
+	case c.CredentialsCommand != "":
+		if bearer != "" {
+			return "", "", "", errors.New("specify either --bearer, --bearer-file or --credentials-command")
+		}
+
+		secret, err := runCredentialsCommand(c.CredentialsCommand)
+		if err != nil {
+			return "", "", "", err
+		}
+
+		bearer = secret
+	}
+
+	if user != "" || bearer != "" || (!c.Netrc && c.NetrcFile == "") {
+		return user, password, bearer, nil
+	}
+
+	path, err := c.netrcPath()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", "", fmt.Errorf("could not read netrc file: %w", err)
+	}
+
+	if login, pass, ok := parseNetrc(string(b), c.targetHost()); ok && login != "" {
+		user, password = login, pass
+	}
+
+	return user, password, bearer, nil
+}
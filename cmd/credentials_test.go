@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseNetrc(t *testing.T) {
+	netrc := `machine other login foo password bar
+machine logstash
+	login icinga
+	password secret:with:colons
+	account ignored
+
+macdef init
+machine logstash login macro password macro
+
+default login anonymous password guest
+`
+
+	tests := map[string][3]string{
+		"logstash": {"icinga", "secret:with:colons", "true"},
+		"other":    {"foo", "bar", "true"},
+		"unknown":  {"anonymous", "guest", "true"},
+	}
+
+	for host, expected := range tests {
+		login, password, ok := parseNetrc(netrc, host)
+		if login != expected[0] || password != expected[1] || !ok {
+			t.Error("\nActual: ", login, password, ok, "\nExpected: ", expected)
+		}
+	}
+
+	_, _, ok := parseNetrc("machine other login foo password bar", "logstash")
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+}
+
+func TestConfigCredentials(t *testing.T) {
+	dir := t.TempDir()
+
+	passwordFile := filepath.Join(dir, "password")
+	_ = os.WriteFile(passwordFile, []byte("file:secret\n"), 0600)
+
+	netrcFile := filepath.Join(dir, "netrc")
+	_ = os.WriteFile(netrcFile, []byte("machine localhost login netrc password secret\n"), 0600)
+
+	tests := []struct {
+		name     string
+		config   Config
+		expected [3]string
+		err      string
+	}{
+		{
+			name:     "password-with-colon",
+			config:   Config{BasicAuth: "icinga:se:cr:et"},
+			expected: [3]string{"icinga", "se:cr:et", ""},
+		},
+		{
+			name:     "password-file",
+			config:   Config{BasicAuth: "icinga", PasswordFile: passwordFile},
+			expected: [3]string{"icinga", "file:secret", ""},
+		},
+		{
+			name:     "command-password",
+			config:   Config{BasicAuth: "icinga", CredentialsCommand: "echo command-secret"},
+			expected: [3]string{"icinga", "command-secret", ""},
+		},
+		{
+			name:     "command-bearer",
+			config:   Config{CredentialsCommand: "printf token"},
+			expected: [3]string{"", "", "token"},
+		},
+		{
+			name:     "bearer-file",
+			config:   Config{BearerFile: passwordFile},
+			expected: [3]string{"", "", "file:secret"},
+		},
+		{
+			name:     "netrc",
+			config:   Config{Hostname: "localhost", NetrcFile: netrcFile},
+			expected: [3]string{"netrc", "secret", ""},
+		},
+		{
+			name:     "netrc-not-used",
+			config:   Config{Hostname: "localhost", NetrcFile: netrcFile, BasicAuth: "icinga:secret"},
+			expected: [3]string{"icinga", "secret", ""},
+		},
+		{
+			name:   "no-password",
+			config: Config{BasicAuth: "icinga"},
+			err:    "specify the user name and password for server authentication <user:password>",
+		},
+		{
+			name:   "two-passwords",
+			config: Config{BasicAuth: "icinga:secret", PasswordFile: passwordFile},
+			err:    "specify the password either with --user, --password-file or --credentials-command",
+		},
+		{
+			name:   "password-file-without-user",
+			config: Config{PasswordFile: passwordFile},
+			err:    "specify the user name of --password-file with --user",
+		},
+		{
+			name:   "command-failed",
+			config: Config{CredentialsCommand: "echo locked >&2; exit 1"},
+			err:    "credentials command failed: exit status 1: locked",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user, password, bearer, err := test.config.credentials()
+
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Error("\nActual: ", err, "\nExpected: ", test.err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			actual := [3]string{user, password, bearer}
+			if actual != test.expected {
+				t.Error("\nActual: ", actual, "\nExpected: ", test.expected)
+			}
+		})
+	}
+}
+
+func TestRunCredentialsCommand(t *testing.T) {
+	runs := filepath.Join(t.TempDir(), "runs")
+	command := "echo run >> " + runs + "; echo secret"
+
+	// The output is cached, so the command runs only once
+	for range 2 {
+		secret, err := runCredentialsCommand(command)
+		if err != nil || secret != "secret" {
+			t.Error("\nActual: ", secret, err, "\nExpected: ", "secret")
+		}
+	}
+
+	b, _ := os.ReadFile(runs)
+	if string(b) != "run\n" {
+		t.Error("\nActual: ", string(b), "\nExpected: ", "run\n")
+	}
+
+	defer func(timeout int) { Timeout = timeout }(Timeout)
+	Timeout = 1
+
+	start := time.Now()
+	_, err := runCredentialsCommand("sleep 10")
+
+	expected := "credentials command timed out after 1s"
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Error("\nActual: ", time.Since(start), "\nExpected: ", "less than 5s")
+	}
+}
+
+func TestHealth_PasswordFile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "icinga" || password != "se:cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	defer ts.Close()
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	_ = os.WriteFile(passwordFile, []byte("se:cret\n"), 0600)
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--url", ts.URL, "--user", "icinga", "--password-file", passwordFile)
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Logstash is healthy"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
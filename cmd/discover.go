@@ -0,0 +1,292 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+)
+
+// DiscoverConfig for the CLI parameters.
+type DiscoverConfig struct {
+	Format       string
+	Plugins      bool
+	Include      []string
+	Exclude      []string
+	Regex        bool
+	HostName     string
+	CheckCommand string
+}
+
+var cliDiscoverConfig DiscoverConfig
+
+// discoverFormats are the supported values of --format
+var discoverFormats = []string{"json", "icinga2-service", "icinga2-apply"}
+
+// discoveredPlugin is a plugin of a discovered pipeline.
+type discoveredPlugin struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// discoveredPipeline is a pipeline of the Logstash node.
+type discoveredPipeline struct {
+	Name      string             `json:"name"`
+	Workers   int                `json:"workers"`
+	BatchSize int                `json:"batch_size"`
+	Plugins   []discoveredPlugin `json:"plugins,omitempty"`
+}
+
+// discovery is the result of the discovery, printed with --format json.
+type discovery struct {
+	Host      string               `json:"host"`
+	Pipelines []discoveredPipeline `json:"pipelines"`
+}
+
+// discoverPipelines lists the pipelines of the Logstash node,
+// with the plugins of each pipeline if requested.
+func discoverPipelines(cfg *Config, dc DiscoverConfig) (*discovery, error) {
+	f, err := newPipelineFilter(dc.Include, dc.Exclude, dc.Regex)
+	if err != nil {
+		return nil, err
+	}
+
+	var np logstash.NodePipelines
+
+	err = fetchAPI(cfg, &np, check.Unknown, "/_node/pipelines")
+	if err != nil {
+		return nil, err
+	}
+
+	// The plugins are only part of the pipeline stats
+	var stats logstash.Pipeline
+
+	if dc.Plugins {
+		err = fetchAPI(cfg, &stats, check.Unknown, "/_node/stats/pipelines")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	d := &discovery{
+		Host:      np.Host,
+		Pipelines: []discoveredPipeline{},
+	}
+
+	for name, info := range np.Pipelines {
+		if !f.Matches(name) {
+			continue
+		}
+
+		p := discoveredPipeline{
+			Name:      name,
+			Workers:   info.Workers,
+			BatchSize: info.BatchSize,
+		}
+
+		if dc.Plugins {
+			pipe := stats.Pipelines[name]
+
+			for _, plugins := range []struct {
+				kind    string
+				plugins []logstash.Plugin
+			}{
+				{"input", pipe.Plugins.Inputs},
+				{"filter", pipe.Plugins.Filters},
+				{"output", pipe.Plugins.Outputs},
+			} {
+				for _, plugin := range plugins.plugins {
+					p.Plugins = append(p.Plugins, discoveredPlugin{ID: plugin.ID, Name: plugin.Name, Type: plugins.kind})
+				}
+			}
+		}
+
+		d.Pipelines = append(d.Pipelines, p)
+	}
+
+	slices.SortFunc(d.Pipelines, func(a, b discoveredPipeline) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return d, nil
+}
+
+// pluginIDs returns the IDs of the plugins as Icinga 2 array.
+func (p discoveredPipeline) pluginIDs() string {
+	ids := make([]string, 0, len(p.Plugins))
+	for _, plugin := range p.Plugins {
+		ids = append(ids, icinga2String(plugin.ID))
+	}
+
+	return "[ " + strings.Join(ids, ", ") + " ]"
+}
+
+// writeIcinga2Services writes a Service object for each pipeline.
+func writeIcinga2Services(w io.Writer, d *discovery, dc DiscoverConfig) {
+	for i, p := range d.Pipelines {
+		if i > 0 {
+			fmt.Fprintf(w, "\n")
+		}
+
+		fmt.Fprintf(w, "object Service %s {\n", icinga2String(dc.CheckCommand+"-"+p.Name))
+		fmt.Fprintf(w, "\thost_name = %s\n", icinga2String(dc.HostName))
+		fmt.Fprintf(w, "\tcheck_command = %s\n", icinga2String(dc.CheckCommand))
+		fmt.Fprintf(w, "\tvars.logstash_pipeline = %s\n", icinga2String(p.Name))
+
+		if dc.Plugins {
+			fmt.Fprintf(w, "\tvars.logstash_plugins = %s\n", p.pluginIDs())
+		}
+
+		fmt.Fprintf(w, "}\n")
+	}
+}
+
+// writeIcinga2Apply writes the pipelines as dictionary for the host
+// and an apply for rule that creates a Service for each pipeline.
+func writeIcinga2Apply(w io.Writer, d *discovery, dc DiscoverConfig) {
+	// A template instead of the Host object, which is already defined in the Icinga 2 configuration
+	fmt.Fprintf(w, "template Host %s {\n", icinga2String("logstash-pipelines-"+dc.HostName))
+	fmt.Fprintf(w, "\tvars.logstash_pipelines = {\n")
+
+	for _, p := range d.Pipelines {
+		if dc.Plugins {
+			fmt.Fprintf(w, "\t\t%s = { plugins = %s }\n", icinga2String(p.Name), p.pluginIDs())
+		} else {
+			fmt.Fprintf(w, "\t\t%s = {}\n", icinga2String(p.Name))
+		}
+	}
+
+	fmt.Fprintf(w, "\t}\n")
+	fmt.Fprintf(w, "}\n\n")
+
+	fmt.Fprintf(w, "apply Service %s for (pipeline => config in host.vars.logstash_pipelines) {\n", icinga2String(dc.CheckCommand+"-"))
+	fmt.Fprintf(w, "\tcheck_command = %s\n", icinga2String(dc.CheckCommand))
+	fmt.Fprintf(w, "\tvars.logstash_pipeline = pipeline\n")
+
+	if dc.Plugins {
+		fmt.Fprintf(w, "\tvars.logstash_plugins = config.plugins\n")
+	}
+
+	fmt.Fprintf(w, "\tassign where host.vars.logstash_pipelines\n")
+	fmt.Fprintf(w, "}\n")
+}
+
+// writeDiscovery writes the discovered pipelines in the format of --format.
+func writeDiscovery(w io.Writer, d *discovery, dc DiscoverConfig) error {
+	switch dc.Format {
+	case "icinga2-service":
+		writeIcinga2Services(w, d, dc)
+	case "icinga2-apply":
+		writeIcinga2Apply(w, d, dc)
+	default:
+		b, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, _ = w.Write(append(b, '\n'))
+	}
+
+	return nil
+}
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Lists the pipelines of the Logstash server",
+	Long: `Lists the pipelines of the Logstash server, e.g. to create a service for each pipeline.
+The pipelines are printed as JSON, as Icinga 2 Service objects or as an Icinga 2 Host template
+with an apply for rule, the template is imported into the Host object of the Logstash server`,
+	Example: `
+	$ check_logstash discover --plugins
+	{
+	  "host": "logstash",
+	  "pipelines": [
+	    {
+	      "name": "example",
+	      "workers": 2,
+	      "batch_size": 125,
+	      "plugins": [
+	        {
+	          "id": "example-input",
+	          "name": "beats",
+	          "type": "input"
+	        }
+	      ]
+	    }
+	  ]
+	}
+
+	$ check_logstash discover --format icinga2-service --host-name logstash1
+	object Service "logstash-pipeline-example" {
+		host_name = "logstash1"
+		check_command = "logstash-pipeline"
+		vars.logstash_pipeline = "example"
+	}
+
+	$ check_logstash discover --format icinga2-apply --host-name logstash1
+	template Host "logstash-pipelines-logstash1" {
+		vars.logstash_pipelines = {
+			"example" = {}
+		}
+	}
+
+	apply Service "logstash-pipeline-" for (pipeline => config in host.vars.logstash_pipelines) {
+		check_command = "logstash-pipeline"
+		vars.logstash_pipeline = pipeline
+		assign where host.vars.logstash_pipelines
+	}`,
+	Annotations: map[string]string{
+		"utility": "true",
+	},
+	Run: func(_ *cobra.Command, _ []string) {
+		dc := cliDiscoverConfig
+
+		if !slices.Contains(discoverFormats, dc.Format) {
+			exitError(fmt.Errorf("invalid format %s, use %s", dc.Format, strings.Join(discoverFormats, ", ")))
+		}
+
+		if dc.HostName == "" {
+			dc.HostName = cliConfig.targetHost()
+		}
+
+		d, err := discoverPipelines(&cliConfig, dc)
+		if err != nil {
+			exitError(err)
+		}
+
+		err = writeDiscovery(os.Stdout, d, dc)
+		if err != nil {
+			exitError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(discoverCmd)
+
+	fs := discoverCmd.Flags()
+
+	fs.StringVar(&cliDiscoverConfig.Format, "format", "json",
+		"Format of the discovered pipelines, use json, icinga2-service or icinga2-apply")
+	fs.BoolVar(&cliDiscoverConfig.Plugins, "plugins", false,
+		"Include the plugins of each pipeline")
+	fs.StringArrayVar(&cliDiscoverConfig.Include, "include", []string{},
+		"Only list pipelines matching this glob pattern. Can be used multiple times")
+	fs.StringArrayVar(&cliDiscoverConfig.Exclude, "exclude", []string{},
+		"Do not list pipelines matching this glob pattern. Can be used multiple times")
+	fs.BoolVar(&cliDiscoverConfig.Regex, "regex", false,
+		"Interpret --include and --exclude as regular expressions instead of glob patterns")
+	fs.StringVar(&cliDiscoverConfig.HostName, "host-name", "",
+		"Name of the Icinga 2 host for the Service objects and the Host template, uses --hostname if not given")
+	fs.StringVar(&cliDiscoverConfig.CheckCommand, "check-command", "logstash-pipeline",
+		"Icinga 2 CheckCommand of the services, see icinga2-config")
+
+	fs.SortFlags = false
+}
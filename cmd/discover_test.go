@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type DiscoverTest struct {
+	name     string
+	server   *httptest.Server
+	args     []string
+	expected string
+}
+
+func discoverServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+
+		if r.URL.Path == "/_node/stats/pipelines" {
+			w.Write([]byte(`{"host":"foobar","version":"8.7.1","id":"4","name":"test","pipelines":{"main":{"plugins":{"inputs":[{"id":"b","name":"beats"}],"filters":[],"outputs":[{"id":"f","name":"redis"}]}},"other":{"plugins":{"inputs":[{"id":"c","name":"http"}],"filters":[],"outputs":[]}}}}`))
+			return
+		}
+
+		w.Write([]byte(`{"host":"foobar","version":"8.7.1","id":"4","name":"test","pipelines":{"other":{"ephemeral_id":"a","workers":1,"batch_size":50},"main":{"ephemeral_id":"b","workers":2,"batch_size":125}}}`))
+	}))
+}
+
+func TestDiscoverCmd(t *testing.T) {
+	tests := []DiscoverTest{
+		{
+			name:   "discover-json",
+			server: discoverServer(),
+			args:   []string{"run", "../main.go", "discover"},
+			expected: `{
+  "host": "foobar",
+  "pipelines": [
+    {
+      "name": "main",
+      "workers": 2,
+      "batch_size": 125
+    },
+    {
+      "name": "other",
+      "workers": 1,
+      "batch_size": 50
+    }
+  ]
+}`,
+		},
+		{
+			name:     "discover-json-plugins",
+			server:   discoverServer(),
+			args:     []string{"run", "../main.go", "discover", "--plugins", "--include", "other"},
+			expected: "\"plugins\": [\n        {\n          \"id\": \"c\",\n          \"name\": \"http\",\n          \"type\": \"input\"\n        }\n      ]",
+		},
+		{
+			name:   "discover-icinga2-service",
+			server: discoverServer(),
+			args:   []string{"run", "../main.go", "discover", "--format", "icinga2-service", "--host-name", "logstash1", "--plugins", "--exclude", "other"},
+			expected: `object Service "logstash-pipeline-main" {
+	host_name = "logstash1"
+	check_command = "logstash-pipeline"
+	vars.logstash_pipeline = "main"
+	vars.logstash_plugins = [ "b", "f" ]
+}
+`,
+		},
+		{
+			name:   "discover-icinga2-apply",
+			server: discoverServer(),
+			args:   []string{"run", "../main.go", "discover", "--format", "icinga2-apply", "--check-command", "logstash-pipeline-flow"},
+			expected: `template Host "logstash-pipelines-localhost" {
+	vars.logstash_pipelines = {
+		"main" = {}
+		"other" = {}
+	}
+}
+
+apply Service "logstash-pipeline-flow-" for (pipeline => config in host.vars.logstash_pipelines) {
+	check_command = "logstash-pipeline-flow"
+	vars.logstash_pipeline = pipeline
+	assign where host.vars.logstash_pipelines
+}
+`,
+		},
+		{
+			name:     "discover-invalid-format",
+			server:   discoverServer(),
+			args:     []string{"run", "../main.go", "discover", "--format", "foo"},
+			expected: "[UNKNOWN] - invalid format foo, use json, icinga2-service, icinga2-apply",
+		},
+		{
+			name:     "discover-invalid-format-json",
+			server:   discoverServer(),
+			args:     []string{"run", "../main.go", "--output", "json", "discover", "--format", "foo"},
+			expected: `"summary": "invalid format foo, use json, icinga2-service, icinga2-apply`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer test.server.Close()
+
+			// We need the random Port extracted
+			u, _ := url.Parse(test.server.URL)
+			cmd := exec.Command("go", append(test.args, "--port", u.Port())...)
+			out, _ := cmd.CombinedOutput()
+
+			actual := string(out)
+
+			if !strings.Contains(actual, test.expected) {
+				t.Error("\nActual: ", actual, "\nExpected: ", test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// envPrefix is the prefix of the environment variables of the flags
+const envPrefix = "CHECK_LOGSTASH_"
+
+// envAliases are previous names of environment variables, which are still supported
+var envAliases = map[string]string{
+	"user": envPrefix + "BASICAUTH",
+}
+
+// envUpper converts a flag or command name for an environment variable, e.g. STATE_FILE for state-file.
+func envUpper(s string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(s))
+}
+
+// envName returns the environment variable of a flag of the command that defines it. The global flags have no
+// namespace, e.g. CHECK_LOGSTASH_STATE_FILE for --state-file, the flags of subcommands are prefixed with
+// the command path, e.g. CHECK_LOGSTASH_PIPELINE_LATENCY_WARNING for --warning of pipeline latency.
+func envName(c *cobra.Command, f *pflag.Flag) string {
+	if !c.HasParent() {
+		return envPrefix + envUpper(f.Name)
+	}
+
+	path := strings.TrimPrefix(c.CommandPath(), c.Root().Name()+" ")
+
+	return envPrefix + envUpper(path) + "_" + envUpper(f.Name)
+}
+
+// flagCommand returns the command that defines a flag of the command,
+// which is the command itself or the parent of an inherited flag.
+func flagCommand(c *cobra.Command, f *pflag.Flag) *cobra.Command {
+	for p := c; p != nil; p = p.Parent() {
+		if p.PersistentFlags().Lookup(f.Name) == f || p.LocalNonPersistentFlags().Lookup(f.Name) == f {
+			return p
+		}
+	}
+
+	return c
+}
+
+// hasEnv reports whether a flag can be set with an environment variable.
+func hasEnv(f *pflag.Flag) bool {
+	return f.Name != "help" && f.Name != "version"
+}
+
+// lookupEnv returns the value of the environment variable of a flag of the command.
+func lookupEnv(c *cobra.Command, f *pflag.Flag) (string, string, bool) {
+	owner := flagCommand(c, f)
+
+	name := envName(owner, f)
+	if v, ok := os.LookupEnv(name); ok {
+		return name, v, true
+	}
+
+	if alias, ok := envAliases[f.Name]; ok && !owner.HasParent() {
+		if v, ok := os.LookupEnv(alias); ok {
+			return alias, v, true
+		}
+	}
+
+	return "", "", false
+}
+
+// addEnvUsage adds the environment variable to the help text of each flag of the command tree.
+func addEnvUsage(c *cobra.Command) {
+	seen := map[*pflag.Flag]bool{}
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		add := func(f *pflag.Flag) {
+			if seen[f] || !hasEnv(f) {
+				return
+			}
+
+			seen[f] = true
+			f.Usage += " (" + envName(c, f) + ")"
+		}
+
+		c.PersistentFlags().VisitAll(add)
+		c.LocalNonPersistentFlags().VisitAll(add)
+
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+
+	walk(c)
+}
+
+// loadEnv sets the flags of the command that are not given on the command line
+// from their environment variables. Flags that can be used multiple times take a single value.
+func loadEnv(cmd *cobra.Command) error {
+	var err error
+
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || !hasEnv(f) {
+			return
+		}
+
+		name, v, ok := lookupEnv(cmd, f)
+		if !ok {
+			return
+		}
+
+		errSet := f.Value.Set(v)
+		if errSet != nil {
+			err = fmt.Errorf("invalid value %q of %s: %w", v, name, errSet)
+		}
+
+		f.Changed = true
+	})
+
+	return err
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEnv(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "" && user+":"+password != "icinga:secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"localhost","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"example":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":0,"in":0},"flow":{"queue_backpressure":{"current":7}}}}}`))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	tests := []struct {
+		name     string
+		env      []string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "env-flags",
+			env:      []string{"CHECK_LOGSTASH_PORT=" + u.Port(), "CHECK_LOGSTASH_PIPELINE_FLOW_WARNING=5", "CHECK_LOGSTASH_PIPELINE_FLOW_CRITICAL=10"},
+			expected: "[WARNING] - queue_backpressure_example:7.00",
+		},
+		{
+			name:     "env-override",
+			env:      []string{"CHECK_LOGSTASH_PORT=" + u.Port(), "CHECK_LOGSTASH_PIPELINE_FLOW_WARNING=5", "CHECK_LOGSTASH_PIPELINE_FLOW_CRITICAL=10"},
+			args:     []string{"--critical", "6"},
+			expected: "[CRITICAL] - queue_backpressure_example:7.00",
+		},
+		{
+			name:     "env-alias",
+			env:      []string{"CHECK_LOGSTASH_PORT=" + u.Port(), "CHECK_LOGSTASH_BASICAUTH=icinga:wrong"},
+			args:     []string{"--warning", "10", "--critical", "20"},
+			expected: "[UNKNOWN] - could not get http://localhost:" + u.Port() + "/_node/stats/pipelines/ - Error: 401",
+		},
+		{
+			name: "env-other-command",
+			env: []string{"CHECK_LOGSTASH_PORT=" + u.Port(), "CHECK_LOGSTASH_SERVE_INTERVAL=30s",
+				"CHECK_LOGSTASH_WARNING=1", "CHECK_LOGSTASH_PIPELINE_LATENCY_WARNING=1"},
+			args:     []string{"--warning", "10", "--critical", "20"},
+			expected: "[OK] - Flow metrics alright",
+		},
+		{
+			name:     "env-invalid",
+			env:      []string{"CHECK_LOGSTASH_PORT=foo"},
+			args:     []string{"--warning", "10", "--critical", "20"},
+			expected: `[UNKNOWN] - invalid value "foo" of CHECK_LOGSTASH_PORT: strconv.ParseInt: parsing "foo": invalid syntax`,
+		},
+		{
+			name:     "env-invalid-json",
+			env:      []string{"CHECK_LOGSTASH_PORT=foo"},
+			args:     []string{"--warning", "10", "--critical", "20", "--output", "json"},
+			expected: `"exit_code": 3,
+  "summary": "invalid value \"foo\" of CHECK_LOGSTASH_PORT: strconv.ParseInt: parsing \"foo\": invalid syntax`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmd := exec.Command("go", append([]string{"run", "../main.go", "pipeline", "flow"}, test.args...)...)
+			cmd.Env = append(os.Environ(), test.env...)
+			out, _ := cmd.CombinedOutput()
+
+			actual := string(out)
+
+			if !strings.Contains(actual, test.expected) {
+				t.Error("\nActual: ", actual, "\nExpected: ", test.expected)
+			}
+		})
+	}
+
+	// The variables of serve do not apply to the flags of the same name of other subcommands
+	cmd := exec.Command("go", "run", "../main.go", "pipeline", "latency", "--warning", "10", "--critical", "20")
+	cmd.Env = append(os.Environ(), "CHECK_LOGSTASH_PORT="+u.Port(), "CHECK_LOGSTASH_SERVE_INTERVAL=30s", "CHECK_LOGSTASH_INTERVAL=30s")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+
+	if !strings.Contains(actual, "[OK] - Event latency alright") {
+		t.Error("\nActual: ", actual, "\nExpected: ", "[OK] - Event latency alright")
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "pipeline", "flow", "--help")
+	out, _ = cmd.CombinedOutput()
+
+	actual = string(out)
+	expected := "Warning threshold for queue Backpressure (CHECK_LOGSTASH_PIPELINE_FLOW_WARNING)"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
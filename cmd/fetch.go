@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/go-check"
+)
+
+// checkError is an error that determines the state of the check,
+// e.g. an unreachable API or a mismatching node identity.
+type checkError struct {
+	state check.Status
+	err   error
+}
+
+func (e *checkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *checkError) Unwrap() error {
+	return e.err
+}
+
+// errorResult returns the state and output for an error,
+// errors without a state of their own are Unknown.
+func errorResult(err error) (check.Status, string) {
+	var ce *checkError
+	if errors.As(err, &ce) {
+		return ce.state, ce.Error()
+	}
+
+	return check.Unknown, fmt.Sprintf("%s (%T)", err.Error(), err)
+}
+
+// exitError exits with the state and output of the error, rendered in the output format.
+func exitError(err error) {
+	state, output := errorResult(err)
+
+	rendered, errRender := renderError(err, cliOutputOptions())
+	if errRender != nil {
+		check.ExitError(errRender)
+	}
+
+	exitResult(state, rendered, "["+state.String()+"] - "+output, nil)
+}
+
+// fetchAPI requests the given path of the Logstash API and decodes the
+// JSON response into v. This is the shared fetch path of all commands,
+// the identity of the Logstash node is verified here as well.
+// Returns an error with the unreachable state if the API cannot be reached.
+func fetchAPI(cfg *Config, v any, unreachable check.Status, elem ...string) error {
+	// Creating an client once per config and connecting to the API
+	if cfg.client == nil {
+		c, err := cfg.NewClient()
+		if err != nil {
+			return err
+		}
+
+		cfg.client = c
+	}
+
+	c := cfg.client
+
+	u, _ := url.JoinPath(c.URL, elem...)
+
+	resp, err := c.Client.Get(u)
+	if err != nil {
+		return &checkError{state: unreachable, err: err}
+	}
+
+	defer resp.Body.Close()
+
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cfg.certNotAfter = resp.TLS.PeerCertificates[0].NotAfter
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get %s - Error: %d", u, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		return err
+	}
+
+	// The node info is part of every /_node response
+	var node logstash.Node
+
+	err = json.Unmarshal(body, &node)
+	if err != nil {
+		return err
+	}
+
+	err = cfg.verifyNodeIdentity(node)
+	if err != nil {
+		mismatchState, errState := check.NewStatus(cfg.IdentityMismatchState)
+		if errState != nil {
+			mismatchState = check.Unknown
+		}
+
+		return &checkError{state: mismatchState, err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/go-check"
+)
+
+// pipelineFilter selects pipelines by their name.
+type pipelineFilter struct {
+	include []func(string) bool
+	exclude []func(string) bool
+}
+
+// newPipelineMatcher returns a matcher for a glob or regular expression pattern.
+func newPipelineMatcher(pattern string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse pipeline pattern %s: %w", pattern, err)
+		}
+
+		return re.MatchString, nil
+	}
+
+	// Validate the glob once, so matching can ignore the error
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse pipeline pattern %s: %w", pattern, err)
+	}
+
+	return func(name string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}, nil
+}
+
+// newPipelineFilter creates a filter from the include and exclude patterns.
+func newPipelineFilter(include, exclude []string, regex bool) (pipelineFilter, error) {
+	var f pipelineFilter
+
+	for _, pattern := range include {
+		m, err := newPipelineMatcher(pattern, regex)
+		if err != nil {
+			return f, err
+		}
+
+		f.include = append(f.include, m)
+	}
+
+	for _, pattern := range exclude {
+		m, err := newPipelineMatcher(pattern, regex)
+		if err != nil {
+			return f, err
+		}
+
+		f.exclude = append(f.exclude, m)
+	}
+
+	return f, nil
+}
+
+// Matches reports whether the pipeline is selected. Without include
+// patterns every pipeline is included, exclude patterns take precedence.
+func (f pipelineFilter) Matches(name string) bool {
+	included := len(f.include) == 0
+
+	for _, m := range f.include {
+		if m(name) {
+			included = true
+			break
+		}
+	}
+
+	if !included {
+		return false
+	}
+
+	for _, m := range f.exclude {
+		if m(name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate checks the selection and sort order of the pipelines,
+// so invalid flags fail before a request is sent to the Logstash API.
+func (pc PipelineConfig) validate() error {
+	if !slices.Contains([]string{"name", "state", "value"}, pc.SortBy) {
+		return fmt.Errorf("invalid sort order %s, use name, state or value", pc.SortBy)
+	}
+
+	_, err := newPipelineFilter(pc.Include, pc.Exclude, pc.Regex)
+
+	return err
+}
+
+// fetchPipelines requests the pipeline stats of the Logstash API
+// and removes the pipelines not selected by --include and --exclude.
+func fetchPipelines(cfg *Config, pc PipelineConfig, pp *logstash.Pipeline) error {
+	f, err := newPipelineFilter(pc.Include, pc.Exclude, pc.Regex)
+	if err != nil {
+		return err
+	}
+
+	// localhost:9600/_node/stats/pipelines/ will return all Pipelines
+	// localhost:9600/_node/stats/pipelines/foo will return the foo Pipeline
+	err = fetchAPI(cfg, pp, check.Unknown, "/_node/stats/pipelines", pc.PipelineName)
+	if err != nil {
+		return err
+	}
+
+	for name := range pp.Pipelines {
+		if !f.Matches(name) {
+			delete(pp.Pipelines, name)
+		}
+	}
+
+	return nil
+}
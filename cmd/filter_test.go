@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestPipelineFilter(t *testing.T) {
+	f, err := newPipelineFilter([]string{"beats-*"}, []string{"beats-test"}, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !f.Matches("beats-prod") {
+		t.Error("\nActual: ", false, "\nExpected: ", true)
+	}
+
+	if f.Matches("beats-test") {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+
+	if f.Matches("syslog") {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+
+	f, err = newPipelineFilter([]string{}, []string{`^\.monitoring`}, true)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !f.Matches("syslog") {
+		t.Error("\nActual: ", false, "\nExpected: ", true)
+	}
+
+	if f.Matches(".monitoring-logstash") {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+
+	_, err = newPipelineFilter([]string{"["}, []string{}, false)
+	if err == nil {
+		t.Error("\nActual: ", err, "\nExpected: ", "error")
+	}
+}
+
+func TestPipelineFilterCmd(t *testing.T) {
+	tests := []PipelineTest{
+		{
+			name: "pipeline-exclude",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"beats-input":{"events":{"out":50,"in":100}},".monitoring-logstash":{"events":{"out":0,"in":100}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "60", "--inflight-events-crit", "80", "--exclude", ".monitoring-*"},
+			expected: "[OK] - Inflight events alright",
+		},
+		{
+			name: "pipeline-include",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"beats-input":{"events":{"out":50,"in":100}},".monitoring-logstash":{"events":{"out":0,"in":100}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "60", "--inflight-events-crit", "80", "--include", ".monitoring-*"},
+			expected: "[CRITICAL] - inflight_events_.monitoring-logstash:100",
+		},
+		{
+			name: "pipeline-exclude-all",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"beats-input":{"events":{"out":50,"in":100}},".monitoring-logstash":{"events":{"out":0,"in":100}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "60", "--inflight-events-crit", "80", "--exclude", "*"},
+			expected: "[UNKNOWN] - Inflight events status unknown",
+		},
+		{
+			name: "pipeline-flow-include-none",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.7.1","pipelines":{"beats-input":{"flow":{"queue_backpressure":{"current":1}}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "5", "--critical", "10", "--include", "syslog-*"},
+			expected: "[UNKNOWN] - Flow metrics status unknown",
+		},
+		{
+			name: "pipeline-flow-regex",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.7.1","pipelines":{"beats-input":{"flow":{"queue_backpressure":{"current":1}}},"x-pack-config-management":{"flow":{"queue_backpressure":{"current":50}}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "5", "--critical", "10", "--exclude", "^x-pack-", "--regex"},
+			expected: "[OK] - Flow metrics alright",
+		},
+		{
+			name: "pipeline-threshold-override",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"beats-input":{"events":{"out":50,"in":100}},"syslog":{"events":{"out":0,"in":10}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "5", "--inflight-events-crit", "20", "--threshold", "beats-*=100:200"},
+			expected: "[WARNING] - inflight_events_syslog:10",
+		},
+		{
+			name: "pipeline-sort-by-name",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"c":{"events":{"out":0,"in":1}},"a":{"events":{"out":0,"in":3}},"b":{"events":{"out":0,"in":2}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "5", "--inflight-events-crit", "20"},
+			expected: "\\_ [OK] pipeline a\n    \\_ [OK] inflight_events_a:3\n\\_ [OK] pipeline b\n    \\_ [OK] inflight_events_b:2\n\\_ [OK] pipeline c\n",
+		},
+		{
+			name: "pipeline-sort-by-value",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"c":{"events":{"out":0,"in":1}},"a":{"events":{"out":0,"in":3}},"b":{"events":{"out":0,"in":2}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "5", "--inflight-events-crit", "20", "--sort-by", "value"},
+			expected: "\\_ [OK] pipeline a\n    \\_ [OK] inflight_events_a:3\n\\_ [OK] pipeline b\n    \\_ [OK] inflight_events_b:2\n\\_ [OK] pipeline c\n",
+		},
+		{
+			name: "pipeline-reload-sort-by-value",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"a":{"reloads":{"last_success_timestamp":"2021-01-01T02:07:14Z","failures":1}},"b":{"reloads":{"last_success_timestamp":"2021-01-01T02:07:14Z","failures":3}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "reload", "--sort-by", "value"},
+			expected: "\\_ [OK] pipeline b\n    \\_ [OK] Configuration successfully reloaded for pipeline b for on 2021-01-01 02:07:14 +0000 UTC\n\\_ [OK] pipeline a\n",
+		},
+		{
+			name: "pipeline-sort-by-state",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.7.1","pipelines":{"a":{"flow":{"queue_backpressure":{"current":1}}},"b":{"flow":{"queue_backpressure":{"current":50}}}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "5", "--critical", "10", "--sort-by", "state"},
+			expected: "\\_ [CRITICAL] pipeline b\n    \\_ [CRITICAL] queue_backpressure_b:50.00\n\\_ [OK] pipeline a\n",
+		},
+		{
+			name: "pipeline-sort-by-invalid",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The flags are validated before the request
+				t.Error("\nActual: ", r.URL, "\nExpected: ", "no request")
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "reload", "--sort-by", "foo"},
+			expected: "[UNKNOWN] - invalid sort order foo",
+		},
+		{
+			name: "pipeline-reload-invalid-pattern",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "reload", "--include", "["},
+			expected: "[UNKNOWN] - could not parse pipeline pattern [",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer test.server.Close()
+
+			// We need the random Port extracted
+			u, _ := url.Parse(test.server.URL)
+			cmd := exec.Command("go", append(test.args, "--port", u.Port())...)
+			out, _ := cmd.CombinedOutput()
+
+			actual := string(out)
+
+			if !strings.Contains(actual, test.expected) {
+				t.Error("\nActual: ", actual, "\nExpected: ", test.expected)
+			}
+
+		})
+	}
+}
@@ -1,12 +1,7 @@
 package cmd
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"net/http"
-	"net/url"
-	"strings"
 
 	"github.com/NETWAYS/check_logstash/internal/logstash"
 	"github.com/NETWAYS/go-check"
@@ -87,39 +82,102 @@ func parseHealthThresholds(config HealthConfig) (HealthThreshold, error) {
 	return t, nil
 }
 
-func generatePerfdata(stat logstash.Stat, thres HealthThreshold) check.PerfdataList {
-	// Generates the Perfdata from the results and thresholds
-	var l check.PerfdataList
+// evaluateHealth evaluates the health of the Logstash server.
+func evaluateHealth(cfg *Config, hc HealthConfig) (*checkOverall, error) {
+	var (
+		overall    checkOverall
+		stat       logstash.Stat
+		thresholds HealthThreshold
+		status     check.Status
+	)
+
+	// Parse the thresholds into a central var since we need them later
+	thresholds, err := parseHealthThresholds(hc)
+	if err != nil {
+		return nil, err
+	}
 
-	l.Add(&check.Perfdata{
-		Label: "process.cpu.percent",
-		Value: stat.Process.CPU.Percent,
-		Uom:   "%",
-		Warn:  thres.cpuUseThresWarn,
-		Crit:  thres.cpuUseThresCrit,
-		Min:   0,
-		Max:   100})
-	l.Add(&check.Perfdata{
+	unreachableExitCode, errExit := check.NewStatus(hc.UnreachableExitCode)
+	if errExit != nil {
+		unreachableExitCode = check.Unknown
+	}
+
+	err = fetchAPI(cfg, &stat, unreachableExitCode, "/_node/stats")
+	if err != nil {
+		return nil, err
+	}
+
+	// Enable some backwards compatibility
+	// Can be changed to a switch statement in the future,
+	// when more versions need special cases
+	// For Logstash 6, we assume a parsed JSON response
+	// is enough to declare the instance running, since there
+	// is no status field.
+	if stat.MajorVersion == 6 {
+		stat.Status = "green"
+	}
+
+	// Logstash Health Status
+	switch stat.Status {
+	default:
+		return nil, errors.New("could not determine status")
+	case "green":
+		status = check.OK
+	case "yellow":
+		status = check.Warning
+	case "red":
+		status = check.Critical
+	}
+
+	overall.AddSubcheck(newStateResult("status", status, "Logstash status %s", stat.Status))
+
+	// Heap Usage Check
+	heap := newThresholdResult("heap_usage", stat.Jvm.Mem.HeapUsedPercent, thresholds.heapUseThresWarn, thresholds.heapUseThresCrit,
+		"Heap usage at %.2f%%", stat.Jvm.Mem.HeapUsedPercent)
+	heap.AddPerfdata(&check.Perfdata{
 		Label: "jvm.mem.heap_used_percent",
 		Uom:   "%",
 		Value: stat.Jvm.Mem.HeapUsedPercent,
-		Warn:  thres.heapUseThresWarn,
-		Crit:  thres.heapUseThresCrit,
+		Warn:  thresholds.heapUseThresWarn,
+		Crit:  thresholds.heapUseThresCrit,
 		Min:   0,
 		Max:   100})
-	l.Add(&check.Perfdata{
+	heap.AddPerfdata(&check.Perfdata{
 		Label: "jvm.threads.count",
 		Value: stat.Jvm.Threads.Count,
 		Max:   0})
-	l.Add(&check.Perfdata{
+	overall.AddSubcheck(heap)
+
+	// File Descriptors Check
+	fileDescriptorsPercent := (stat.Process.OpenFileDescriptors / stat.Process.MaxFileDescriptors) * 100
+
+	fd := newThresholdResult("open_file_descriptors", fileDescriptorsPercent, thresholds.fileDescThresWarn, thresholds.fileDescThresCrit,
+		"Open file descriptors at %.2f%%", fileDescriptorsPercent)
+	fd.AddPerfdata(&check.Perfdata{
 		Label: "process.open_file_descriptors",
 		Value: stat.Process.OpenFileDescriptors,
-		Warn:  thres.fileDescThresWarn,
-		Crit:  thres.fileDescThresCrit,
+		Warn:  thresholds.fileDescThresWarn,
+		Crit:  thresholds.fileDescThresCrit,
 		Min:   0,
 		Max:   stat.Process.MaxFileDescriptors})
+	overall.AddSubcheck(fd)
 
-	return l
+	// CPU Usage Check
+	cpu := newThresholdResult("cpu_usage", stat.Process.CPU.Percent, thresholds.cpuUseThresWarn, thresholds.cpuUseThresCrit,
+		"CPU usage at %.2f%%", stat.Process.CPU.Percent)
+	cpu.AddPerfdata(&check.Perfdata{
+		Label: "process.cpu.percent",
+		Value: stat.Process.CPU.Percent,
+		Uom:   "%",
+		Warn:  thresholds.cpuUseThresWarn,
+		Crit:  thresholds.cpuUseThresCrit,
+		Min:   0,
+		Max:   100})
+	overall.AddSubcheck(cpu)
+
+	overall.SetOKSummary("Logstash is healthy")
+
+	return &overall, nil
 }
 
 var healthCmd = &cobra.Command{
@@ -128,148 +186,26 @@ var healthCmd = &cobra.Command{
 	Long:  `Checks the health of the Logstash server`,
 	Example: `
 	$ check_logstash health --hostname 'localhost' --port 8888 --insecure
-	OK - Logstash is healthy | status=green process.cpu.percent=0;0.5;3;0;100
-	 \_[OK] Heap usage at 12.00%
-	 \_[OK] Open file descriptors at 12.00%
-	 \_[OK] CPU usage at 5.00%
+	[OK] - Logstash is healthy
+	\_ [OK] Logstash status green
+	\_ [OK] Heap usage at 12.00%
+	\_ [OK] Open file descriptors at 12.00%
+	\_ [OK] CPU usage at 5.00%
+	|jvm.mem.heap_used_percent=12%;70;80;0;100 jvm.threads.count=25;;;;0 process.open_file_descriptors=120;100;100;0;1024 process.cpu.percent=5%;100;100;0;100
 
 	$ check_logstash -p 9600 health --cpu-usage-threshold-warn 50 --cpu-usage-threshold-crit 75
-	WARNING - CPU usage at 55.00%
-	 \_[OK] Heap usage at 12.00%
-	 \_[OK] Open file descriptors at 12.00%
-	 \_[WARNING] CPU usage at 55.00%`,
+	[WARNING] - CPU usage at 55.00%
+	\_ [OK] Logstash status green
+	\_ [OK] Heap usage at 12.00%
+	\_ [OK] Open file descriptors at 12.00%
+	\_ [WARNING] CPU usage at 55.00%`,
 	Run: func(_ *cobra.Command, _ []string) {
-		var (
-			output     string
-			rc         check.Status
-			stat       logstash.Stat
-			thresholds HealthThreshold
-			fdstatus   string
-			heapstatus string
-			cpustatus  string
-		)
-
-		// status + fdstatus + heapstatus + cpustatus = 4
-		states := make([]check.Status, 0, 4)
-
-		// Parse the thresholds into a central var since we need them later
-		thresholds, err := parseHealthThresholds(cliHealthConfig)
+		overall, err := evaluateHealth(&cliConfig, cliHealthConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		unreachableExitCode, errExit := check.NewStatus(cliHealthConfig.UnreachableExitCode)
-		if errExit != nil {
-			unreachableExitCode = check.Unknown
-		}
-
-		// Creating an client and connecting to the API
-		c := cliConfig.NewClient()
-		u, _ := url.JoinPath(c.URL, "/_node/stats")
-
-		resp, err := c.Client.Get(u)
-		if err != nil {
-			check.Exit(unreachableExitCode, err.Error())
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			check.ExitError(fmt.Errorf("could not get %s - Error: %d", u, resp.StatusCode))
-		}
-
-		defer resp.Body.Close()
-
-		err = json.NewDecoder(resp.Body).Decode(&stat)
-		if err != nil {
-			check.ExitError(err)
-		}
-
-		// Enable some backwards compatibility
-		// Can be changed to a switch statement in the future,
-		// when more versions need special cases
-		// For Logstash 6, we assume a parsed JSON response
-		// is enough to declare the instance running, since there
-		// is no status field.
-		if stat.MajorVersion == 6 {
-			stat.Status = "green"
-		}
-
-		// Logstash Health Status
-		switch stat.Status {
-		default:
-			check.ExitError(errors.New("could not determine status"))
-		case "green":
-			states = append(states, check.OK)
-		case "yellow":
-			states = append(states, check.Warning)
-		case "red":
-			states = append(states, check.Critical)
-		}
-
-		perfList := generatePerfdata(stat, thresholds)
-
-		// Defaults for the subchecks
-		fdstatus = check.OKString
-		heapstatus = check.OKString
-		cpustatus = check.OKString
-
-		// File Descriptors Check
-		fileDescriptorsPercent := (stat.Process.OpenFileDescriptors / stat.Process.MaxFileDescriptors) * 100
-		if thresholds.fileDescThresWarn.DoesViolate(fileDescriptorsPercent) {
-			states = append(states, check.Warning)
-			fdstatus = check.WarningString
-		}
-
-		if thresholds.fileDescThresCrit.DoesViolate(fileDescriptorsPercent) {
-			states = append(states, check.Critical)
-			fdstatus = check.CriticalString
-		}
-
-		// Heap Usage Check
-		if thresholds.heapUseThresWarn.DoesViolate(stat.Jvm.Mem.HeapUsedPercent) {
-			states = append(states, check.Warning)
-			heapstatus = check.WarningString
-		}
-
-		if thresholds.heapUseThresCrit.DoesViolate(stat.Jvm.Mem.HeapUsedPercent) {
-			states = append(states, check.Critical)
-			heapstatus = check.CriticalString
-		}
-
-		// CPU Usage Check
-		if thresholds.cpuUseThresWarn.DoesViolate(stat.Process.CPU.Percent) {
-			states = append(states, check.Warning)
-			cpustatus = check.WarningString
-		}
-
-		if thresholds.cpuUseThresCrit.DoesViolate(stat.Process.CPU.Percent) {
-			states = append(states, check.Critical)
-			cpustatus = check.CriticalString
-		}
-
-		// Validate the various subchecks and use the worst state as return code
-		//nolint: exhaustive
-		switch check.WorstState(states...) {
-		case 0:
-			rc = check.OK
-			output = "Logstash is healthy"
-		case 1:
-			rc = check.Warning
-			output = "Logstash may not be healthy"
-		case 2:
-			rc = check.Critical
-			output = "Logstash is unhealthy"
-		default:
-			rc = check.Unknown
-			output = "Status unknown"
-		}
-
-		// Generate summary for subchecks
-		var summary strings.Builder
-		fmt.Fprintf(&summary, "\n \\_[%s] Heap usage at %.2f%%", heapstatus, stat.Jvm.Mem.HeapUsedPercent)
-		fmt.Fprintf(&summary, "\n \\_[%s] Open file descriptors at %.2f%%", fdstatus, fileDescriptorsPercent)
-		fmt.Fprintf(&summary, "\n \\_[%s] CPU usage at %.2f%%", cpustatus, stat.Process.CPU.Percent)
-
-		check.ExitWithPerfdata(rc, perfList, output, summary.String())
+		exitOverall(overall)
 	},
 }
 
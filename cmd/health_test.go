@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -21,6 +25,35 @@ func TestHealth_ConnectionRefused(t *testing.T) {
 	}
 }
 
+func TestHealth_ConnectionRefusedJSON(t *testing.T) {
+	cmd := exec.Command("go", "run", "../main.go", "health", "--port", "9999", "--output", "json")
+	out, _ := cmd.Output()
+
+	var actual jsonOutput
+
+	// The output is a single JSON document
+	err := json.Unmarshal(out, &actual)
+	if err != nil {
+		t.Fatal(err, string(out))
+	}
+
+	if actual.State != "UNKNOWN" || !strings.HasPrefix(actual.Summary, "Get \"http://localhost:9999/") {
+		t.Error("\nActual: ", actual, "\nExpected: ", "UNKNOWN")
+	}
+}
+
+func TestHealth_ConnectionRefusedOpenMetrics(t *testing.T) {
+	cmd := exec.Command("go", "run", "../main.go", "health", "--port", "9999", "--output", "openmetrics")
+	out, _ := cmd.Output()
+
+	actual := string(out)
+	expected := "# TYPE check_logstash_state gauge\ncheck_logstash_state{command=\"health\",host=\"localhost\"} 3\n# EOF\n"
+
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
 func TestHealth_ConnectionRefusedCritical(t *testing.T) {
 	cmd := exec.Command("go", "run", "../main.go", "health", "--port", "9999", "--unreachable-state", "2")
 	out, _ := cmd.CombinedOutput()
@@ -143,6 +176,33 @@ func TestHealthCmd_Logstash7(t *testing.T) {
 			args:     []string{"run", "../main.go", "health"},
 			expected: "[OK] - Logstash is healthy",
 		},
+		{
+			name: "health-identity-ok",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--expect-host", "test"},
+			expected: "[OK] - Logstash is healthy",
+		},
+		{
+			name: "health-identity-mismatch",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--expect-host", "other"},
+			expected: "[CRITICAL] - expected host other, got test",
+		},
+		{
+			name: "health-identity-mismatch-warning",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--expect-host", "other", "--identity-mismatch-state", "1"},
+			expected: "[WARNING] - expected host other, got test",
+		},
 		{
 			name: "health-perfdata",
 			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -150,7 +210,7 @@ func TestHealthCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
 			})),
 			args:     []string{"run", "../main.go", "health"},
-			expected: "|process.cpu.percent=1%;100;100;0;100 jvm.mem.heap_used_percent=20%;70;80;0;100 jvm.threads.count=50;;;;0 process.open_file_descriptors=120;100;100;0;16384",
+			expected: "|jvm.mem.heap_used_percent=20%;70;80;0;100 jvm.threads.count=50;;;;0 process.open_file_descriptors=120;100;100;0;16384 process.cpu.percent=1%;100;100;0;100",
 		},
 		{
 			name: "health-red",
@@ -159,7 +219,7 @@ func TestHealthCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"red","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
 			})),
 			args:     []string{"run", "../main.go", "health"},
-			expected: "[CRITICAL] - Logstash is unhealthy",
+			expected: "[CRITICAL] - Logstash status red",
 		},
 		{
 			name: "health-filedesc-ok",
@@ -233,6 +293,42 @@ func TestHealthCmd_Logstash7(t *testing.T) {
 			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--cpu-usage-threshold-crit", "50"},
 			expected: "[WARNING] CPU usage at 45.00%",
 		},
+		{
+			name: "health-only-problems",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":50}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--cpu-usage-threshold-crit", "50", "--only-problems"},
+			expected: "[WARNING] - CPU usage at 45.00% (3 OK, 1 WARNING)\n\\_ [WARNING] CPU usage at 45.00%\n|",
+		},
+		{
+			name: "health-output-json",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":50}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--cpu-usage-threshold-crit", "50", "--output", "json"},
+			expected: "\"state\": \"WARNING\",\n  \"exit_code\": 1,\n  \"summary\": \"CPU usage at 45.00%\"",
+		},
+		{
+			name: "health-output-openmetrics",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":50}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--cpu-usage-threshold-crit", "50", "--output", "openmetrics"},
+			expected: "# TYPE logstash_process_cpu_percent gauge\nlogstash_process_cpu_percent{host=\"localhost\"} 45\n# TYPE check_logstash_state gauge\ncheck_logstash_state{command=\"health\",host=\"localhost\"} 1\n# EOF\n",
+		},
+		{
+			name: "health-output-invalid",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":50}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
+			})),
+			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--cpu-usage-threshold-crit", "50", "--output", "xml"},
+			expected: "[UNKNOWN] - invalid output format xml, use icinga, json, openmetrics",
+		},
 		{
 			name: "health-cpuuse-crit",
 			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -249,7 +345,7 @@ func TestHealthCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"test","version":"7.17.8","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":55}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
 			})),
 			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--heap-usage-threshold-crit", "50"},
-			expected: "[CRITICAL] - Logstash is unhealthy",
+			expected: "[CRITICAL] - Heap usage at 55.00%",
 		},
 	}
 
@@ -290,7 +386,7 @@ func TestHealthCmd_Logstash8(t *testing.T) {
 				w.Write([]byte(`{"host":"test","version":"8.6","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20}},"process":{"open_file_descriptors": 120,"peak_open_file_descriptors": 120,"max_file_descriptors":16384,"cpu":{"percent": 1}}}`))
 			})),
 			args:     []string{"run", "../main.go", "health"},
-			expected: "|process.cpu.percent=1%;100;100;0;100 jvm.mem.heap_used_percent=20%;70;80;0;100 jvm.threads.count=50;;;;0 process.open_file_descriptors=120;100;100;0;16384",
+			expected: "|jvm.mem.heap_used_percent=20%;70;80;0;100 jvm.threads.count=50;;;;0 process.open_file_descriptors=120;100;100;0;16384 process.cpu.percent=1%;100;100;0;100",
 		},
 		{
 			name: "health-cpu-heap-worst-state",
@@ -299,7 +395,7 @@ func TestHealthCmd_Logstash8(t *testing.T) {
 				w.Write([]byte(`{"host":"test","version":"8.6","status":"green","jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":55}},"process":{"open_file_descriptors": 51,"peak_open_file_descriptors": 50,"max_file_descriptors":100,"cpu":{"percent": 45}}}`))
 			})),
 			args:     []string{"run", "../main.go", "health", "--cpu-usage-threshold-warn", "40", "--heap-usage-threshold-crit", "50"},
-			expected: "[CRITICAL] - Logstash is unhealthy",
+			expected: "[CRITICAL] - Heap usage at 55.00%",
 		},
 	}
 
@@ -321,3 +417,102 @@ func TestHealthCmd_Logstash8(t *testing.T) {
 		})
 	}
 }
+
+func TestHealth_URLPathPrefix(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/logstash-eu/_node/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	defer ts.Close()
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--url", ts.URL+"/logstash-eu/", "--port", "9999")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Logstash is healthy"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestHealth_UnixSocketAndResolve(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "logstash.sock")
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	ts.Listener = l
+	ts.Start()
+	defer ts.Close()
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--unix-socket", socket, "--hostname", "logstash.invalid")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Logstash is healthy"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	tcp := httptest.NewServer(ts.Config.Handler)
+	defer tcp.Close()
+
+	u, _ := url.Parse(tcp.URL)
+
+	cmd = exec.Command("go", "run", "../main.go", "health", "--hostname", "logstash.invalid", "--port", u.Port(),
+		"--resolve", "logstash.invalid:"+u.Port()+":127.0.0.1")
+	out, _ = cmd.CombinedOutput()
+
+	actual = string(out)
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestHealth_APIKeyAndHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "ApiKey secret" || r.Header.Get("X-Tenant") != "eu" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	defer ts.Close()
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--url", ts.URL, "--header", "X-Tenant: eu")
+	cmd.Env = append(os.Environ(), "CHECK_LOGSTASH_API_KEY=secret")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Logstash is healthy"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "health", "--url", ts.URL, "--header", "X-Tenant")
+	out, _ = cmd.CombinedOutput()
+
+	actual = string(out)
+	expected = "could not parse header X-Tenant, use 'Name: value'"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// icinga2Escaper escapes strings in the Icinga 2 configuration language
+var icinga2Escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// icinga2String renders a quoted string in the Icinga 2 configuration language.
+func icinga2String(s string) string {
+	return `"` + icinga2Escaper.Replace(s) + `"`
+}
+
+// icinga2VarName returns the custom variable of a flag, e.g. logstash_inflight_events_warn.
+func icinga2VarName(f *pflag.Flag) string {
+	return "logstash_" + strings.ReplaceAll(f.Name, "-", "_")
+}
+
+// icinga2Default renders the default value of a flag for the vars of the CheckCommand.
+// Returns false for flags without a default, since these are only passed if set.
+func icinga2Default(f *pflag.Flag) (string, bool) {
+	// The address of the host is a better default than localhost
+	if f.Name == "hostname" {
+		return `"$check_address$"`, true
+	}
+
+	if v, ok := f.Value.(pflag.SliceValue); ok {
+		if len(v.GetSlice()) == 0 {
+			return "", false
+		}
+
+		values := make([]string, 0, len(v.GetSlice()))
+		for _, s := range v.GetSlice() {
+			values = append(values, icinga2String(s))
+		}
+
+		return "[ " + strings.Join(values, ", ") + " ]", true
+	}
+
+	switch f.Value.Type() {
+	case "bool":
+		return f.DefValue, f.DefValue == "true"
+	case "int", "float64":
+		return f.DefValue, f.DefValue != "0"
+	case "duration":
+		return icinga2String(f.DefValue), f.DefValue != "0s"
+	}
+
+	return icinga2String(f.DefValue), f.DefValue != ""
+}
+
+// icinga2Flags returns the flags of a command in the order of the help text,
+// the global flags first, then the flags of the parent commands and the command itself.
+func icinga2Flags(c *cobra.Command) []*pflag.Flag {
+	var (
+		flags []*pflag.Flag
+		seen  = map[string]bool{}
+	)
+
+	add := func(f *pflag.Flag) {
+		if seen[f.Name] || f.Hidden || f.Name == "help" || f.Name == "version" {
+			return
+		}
+
+		seen[f.Name] = true
+		flags = append(flags, f)
+	}
+
+	var parents []*cobra.Command
+	for p := c.Parent(); p != nil; p = p.Parent() {
+		parents = append([]*cobra.Command{p}, parents...)
+	}
+
+	for _, p := range parents {
+		p.PersistentFlags().VisitAll(add)
+	}
+
+	c.PersistentFlags().VisitAll(add)
+	c.Flags().VisitAll(add)
+
+	return flags
+}
+
+// isCheckCommand returns true for the subcommands that run a check,
+// as opposed to utilities like serve.
+func isCheckCommand(c *cobra.Command) bool {
+	return c.Runnable() && c.HasParent() && !c.Hidden && c.Annotations["utility"] == ""
+}
+
+// writeIcinga2CheckCommand writes the CheckCommand object of a subcommand.
+func writeIcinga2CheckCommand(w io.Writer, c *cobra.Command) {
+	path := strings.Fields(strings.TrimPrefix(c.CommandPath(), c.Root().Name()))
+
+	command := []string{`PluginContribDir + "/` + c.Root().Name() + `"`}
+	for _, p := range path {
+		command = append(command, icinga2String(p))
+	}
+
+	fmt.Fprintf(w, "object CheckCommand %s {\n", icinga2String("logstash-"+strings.Join(path, "-")))
+	fmt.Fprintf(w, "\timport \"plugin-check-command\"\n")
+	fmt.Fprintf(w, "\tcommand = [ %s ]\n\n", strings.Join(command, ", "))
+	fmt.Fprintf(w, "\targuments = {\n")
+
+	flags := icinga2Flags(c)
+
+	for _, f := range flags {
+		fmt.Fprintf(w, "\t\t%s = {\n", icinga2String("--"+f.Name))
+
+		if f.Value.Type() == "bool" {
+			fmt.Fprintf(w, "\t\t\tset_if = %s\n", icinga2String("$"+icinga2VarName(f)+"$"))
+		} else {
+			fmt.Fprintf(w, "\t\t\tvalue = %s\n", icinga2String("$"+icinga2VarName(f)+"$"))
+		}
+
+		if _, ok := f.Value.(pflag.SliceValue); ok {
+			fmt.Fprintf(w, "\t\t\trepeat_key = true\n")
+		}
+
+		// The other arguments are only passed if their custom variable is set
+		if f.Annotations[cobra.BashCompOneRequiredFlag] != nil {
+			fmt.Fprintf(w, "\t\t\trequired = true\n")
+		} else {
+			fmt.Fprintf(w, "\t\t\trequired = false\n")
+		}
+
+		fmt.Fprintf(w, "\t\t\tdescription = %s\n", icinga2String(f.Usage))
+		fmt.Fprintf(w, "\t\t}\n")
+	}
+
+	fmt.Fprintf(w, "\t}\n")
+
+	var vars bool
+
+	for _, f := range flags {
+		if v, ok := icinga2Default(f); ok {
+			if !vars {
+				fmt.Fprintf(w, "\n")
+
+				vars = true
+			}
+
+			fmt.Fprintf(w, "\tvars.%s = %s\n", icinga2VarName(f), v)
+		}
+	}
+
+	fmt.Fprintf(w, "}\n")
+}
+
+// writeIcinga2Config writes a CheckCommand object for each check subcommand of the command tree.
+func writeIcinga2Config(w io.Writer, root *cobra.Command) {
+	first := true
+
+	var walk func(c *cobra.Command)
+	walk = func(c *cobra.Command) {
+		if isCheckCommand(c) {
+			if !first {
+				fmt.Fprintf(w, "\n")
+			}
+
+			first = false
+
+			writeIcinga2CheckCommand(w, c)
+		}
+
+		for _, sub := range c.Commands() {
+			walk(sub)
+		}
+	}
+
+	walk(root)
+}
+
+var icinga2ConfigCmd = &cobra.Command{
+	Use:   "icinga2-config",
+	Short: "Prints the Icinga 2 CheckCommand definitions of all subcommands",
+	Long: `Prints an Icinga 2 CheckCommand object for each subcommand, with an argument for each flag.
+The custom variables are named after the flags, e.g. --inflight-events-warn becomes vars.logstash_inflight_events_warn,
+and the defaults are taken from the flag defaults`,
+	Example: `
+	$ check_logstash icinga2-config
+	object CheckCommand "logstash-health" {
+		import "plugin-check-command"
+		command = [ PluginContribDir + "/check_logstash", "health" ]
+
+		arguments = {
+			"--hostname" = {
+				value = "$logstash_hostname$"
+				description = "Hostname of the Logstash server (CHECK_LOGSTASH_HOSTNAME)"
+			}
+			...
+		}
+
+		vars.logstash_hostname = "$check_address$"
+		vars.logstash_port = 9600
+		...
+	}`,
+	Annotations: map[string]string{
+		"utility": "true",
+	},
+	Run: func(cmd *cobra.Command, _ []string) {
+		writeIcinga2Config(os.Stdout, cmd.Root())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(icinga2ConfigCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteIcinga2Config(t *testing.T) {
+	var b bytes.Buffer
+
+	writeIcinga2Config(&b, rootCmd)
+
+	actual := b.String()
+
+	expected := []string{
+		"object CheckCommand \"logstash-health\" {\n\timport \"plugin-check-command\"\n\tcommand = [ PluginContribDir + \"/check_logstash\", \"health\" ]\n",
+		"object CheckCommand \"logstash-pipeline-flow\" {\n\timport \"plugin-check-command\"\n\tcommand = [ PluginContribDir + \"/check_logstash\", \"pipeline\", \"flow\" ]\n",
+		"\t\t\"--secure\" = {\n\t\t\tset_if = \"$logstash_secure$\"\n\t\t\trequired = false\n\t\t\tdescription = \"Use a HTTPS connection\"\n\t\t}\n",
+		"\t\t\"--include\" = {\n\t\t\tvalue = \"$logstash_include$\"\n\t\t\trepeat_key = true\n\t\t\trequired = false\n",
+		"\t\t\"--inflight-events-warn\" = {\n\t\t\tvalue = \"$logstash_inflight_events_warn$\"\n\t\t\trequired = true\n",
+		"\tvars.logstash_hostname = \"$check_address$\"\n\tvars.logstash_port = 9600\n",
+		"\tvars.logstash_heap_usage_threshold_warn = \"70\"\n",
+		"\tvars.logstash_window = \"1h0m0s\"\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(actual, e) {
+			t.Error("\nActual: ", actual, "\nExpected: ", e)
+		}
+	}
+
+	for _, c := range []string{"logstash-serve", "logstash-icinga2-config", "\"--help\""} {
+		if strings.Contains(actual, c) {
+			t.Error("\nActual: ", actual, "\nExpected not: ", c)
+		}
+	}
+}
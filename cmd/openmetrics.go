@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/NETWAYS/go-check"
+)
+
+// metricNameRe matches the characters that are not allowed in metric names
+var metricNameRe = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// metricFamily is a metric with all its samples, which are rendered together.
+type metricFamily struct {
+	name    string
+	kind    string
+	samples []string
+}
+
+// metricName converts a perfdata label to a metric name. The pipeline and plugin
+// are removed from the label, since they are added as labels to the metric.
+func metricName(label string, labels map[string]string) string {
+	if pipeline, ok := labels["pipeline"]; ok {
+		if strings.HasPrefix(label, "pipelines."+pipeline+".") {
+			label = "pipelines." + strings.TrimPrefix(label, "pipelines."+pipeline+".")
+		}
+
+		label = strings.TrimSuffix(label, "_"+pipeline)
+	}
+
+	if plugin, ok := labels["plugin"]; ok {
+		label = strings.Replace(label, ".plugins."+plugin+".", ".plugins.", 1)
+	}
+
+	return "logstash_" + metricNameRe.ReplaceAllString(label, "_")
+}
+
+// formatMetricLabels renders the labels sorted by name.
+func formatMetricLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	pairs := make([]string, 0, len(keys))
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, k, escaper.Replace(labels[k])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// formatMetricValue renders a perfdata value.
+func formatMetricValue(value any) string {
+	switch v := value.(type) {
+	case float64:
+		return check.FormatFloat(v)
+	case float32:
+		return check.FormatFloat(float64(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// metricSet collects the metric families of one or more overall results.
+type metricSet struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+	series   map[string]bool
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		index:  map[string]*metricFamily{},
+		series: map[string]bool{},
+	}
+}
+
+// add adds a sample to its metric family, duplicate series are skipped.
+func (m *metricSet) add(name, kind, series, value string) {
+	if m.series[series] {
+		return
+	}
+
+	m.series[series] = true
+
+	f, ok := m.index[name]
+	if !ok {
+		f = &metricFamily{name: name, kind: kind}
+		m.index[name] = f
+		m.families = append(m.families, f)
+	}
+
+	f.samples = append(f.samples, series+" "+value)
+}
+
+// addState adds the state of a check.
+func (m *metricSet) addState(state check.Status, host, command string) {
+	m.add("check_logstash_state", "gauge", "check_logstash_state"+
+		formatMetricLabels(map[string]string{"host": host, "command": command}), fmt.Sprint(int(state)))
+}
+
+// addOverall adds the perfdata of the overall result as metrics,
+// with a counter for each perfdata with a "c" UOM and a gauge otherwise.
+func (m *metricSet) addOverall(o *checkOverall, host string) {
+	var collect func(c *checkResult, parent map[string]string)
+	collect = func(c *checkResult, parent map[string]string) {
+		labels := maps.Clone(parent)
+		maps.Copy(labels, c.labels)
+
+		for _, p := range c.perfdata {
+			name := metricName(p.Label, labels)
+
+			if p.Uom == "c" {
+				m.add(name, "counter", name+"_total"+formatMetricLabels(labels), formatMetricValue(p.Value))
+			} else {
+				m.add(name, "gauge", name+formatMetricLabels(labels), formatMetricValue(p.Value))
+			}
+		}
+
+		for _, sub := range c.subchecks {
+			collect(sub, labels)
+		}
+	}
+
+	for _, c := range o.subchecks {
+		collect(c, map[string]string{"host": host})
+	}
+}
+
+// String renders the metrics in the OpenMetrics text format.
+func (m *metricSet) String() string {
+	var b strings.Builder
+
+	for _, f := range m.families {
+		fmt.Fprintf(&b, "# TYPE %s %s\n", f.name, f.kind)
+
+		for _, s := range f.samples {
+			b.WriteString(s + "\n")
+		}
+	}
+
+	b.WriteString("# EOF\n")
+
+	return b.String()
+}
+
+// renderOpenMetrics renders the perfdata and the state of the overall result in the OpenMetrics text format.
+func renderOpenMetrics(o *checkOverall, host, command string) string {
+	m := newMetricSet()
+	m.addOverall(o, host)
+	m.addState(o.GetStatus(), host, command)
+
+	return m.String()
+}
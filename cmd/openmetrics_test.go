@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/go-check"
+)
+
+func TestMetricName(t *testing.T) {
+	labels := map[string]string{"pipeline": "example", "plugin": "f"}
+
+	tests := map[string]string{
+		"pipelines.example.events.in":                 "logstash_pipelines_events_in",
+		"inflight_events_example":                     "logstash_inflight_events",
+		"pipelines.queue_backpressure_example":        "logstash_pipelines_queue_backpressure",
+		"pipelines.example.plugins.f.event_latency":   "logstash_pipelines_plugins_event_latency",
+		"jvm.mem.heap_used_percent":                   "logstash_jvm_mem_heap_used_percent",
+		"pipelines.example.events.duration_in_millis": "logstash_pipelines_events_duration_in_millis",
+	}
+
+	for label, expected := range tests {
+		actual := metricName(label, labels)
+		if actual != expected {
+			t.Error("\nActual: ", actual, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestRenderOpenMetrics(t *testing.T) {
+	var o checkOverall
+
+	r := newPipelineResult("example")
+	r.partial.AddPerfdata(&check.Perfdata{Label: "pipelines.example.events.duration_in_millis", Uom: "c", Value: 500})
+
+	latency := newThresholdResult("event_latency", 10, nil, nil, "event_latency_example:10.00ms")
+	latency.AddPerfdata(&check.Perfdata{Label: "pipelines.event_latency_example", Uom: "ms", Value: 10.0})
+	r.partial.AddSubcheck(latency)
+
+	plugin := newPluginResult(logstash.Plugin{ID: "f", Name: "redis"})
+	metric := newThresholdResult("event_latency", 2, nil, nil, "event_latency_f:2.00ms")
+	metric.AddPerfdata(&check.Perfdata{Label: "pipelines.example.plugins.f.event_latency", Uom: "ms", Value: 2.0})
+	plugin.AddSubcheck(metric)
+	r.partial.AddSubcheck(plugin)
+
+	o.AddSubcheck(r.partial)
+
+	actual := renderOpenMetrics(&o, "localhost", "pipeline latency")
+
+	expected := `# TYPE logstash_pipelines_events_duration_in_millis counter
+logstash_pipelines_events_duration_in_millis_total{host="localhost",pipeline="example"} 500
+# TYPE logstash_pipelines_event_latency gauge
+logstash_pipelines_event_latency{host="localhost",pipeline="example"} 10
+# TYPE logstash_pipelines_plugins_event_latency gauge
+logstash_pipelines_plugins_event_latency{host="localhost",pipeline="example",plugin="f"} 2
+# TYPE check_logstash_state gauge
+check_logstash_state{command="pipeline latency",host="localhost"} 0
+# EOF
+`
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/NETWAYS/go-check"
+)
+
+// outputFormats are the supported values of --output
+var outputFormats = []string{"icinga", "json", "openmetrics"}
+
+// jsonOutput is the document printed with --output json
+type jsonOutput struct {
+	State    string      `json:"state"`
+	ExitCode int         `json:"exit_code"`
+	Summary  string      `json:"summary"`
+	Checks   []jsonCheck `json:"checks"`
+	Perfdata string      `json:"perfdata,omitempty"`
+}
+
+// jsonCheck is a subcheck in the JSON output
+type jsonCheck struct {
+	Name     string         `json:"name"`
+	State    string         `json:"state"`
+	Value    *float64       `json:"value,omitempty"`
+	Warning  string         `json:"warning,omitempty"`
+	Critical string         `json:"critical,omitempty"`
+	Message  string         `json:"message"`
+	Perfdata []jsonPerfdata `json:"perfdata,omitempty"`
+	Checks   []jsonCheck    `json:"checks,omitempty"`
+}
+
+// jsonPerfdata is a single perfdata value in the JSON output
+type jsonPerfdata struct {
+	Label    string `json:"label"`
+	Value    any    `json:"value"`
+	Uom      string `json:"uom,omitempty"`
+	Warning  string `json:"warning,omitempty"`
+	Critical string `json:"critical,omitempty"`
+	Min      any    `json:"min,omitempty"`
+	Max      any    `json:"max,omitempty"`
+}
+
+// subResultRe matches a sub-result line of the long output, e.g. "  \_ [OK] foo"
+var subResultRe = regexp.MustCompile(`^(\s*)\\_ \[(OK|WARNING|CRITICAL|UNKNOWN)\]`)
+
+// filterProblems removes the OK sub-results from the output of a result tree and
+// adds the number of top-level sub-results per state to the summary line.
+func filterProblems(output string) string {
+	var (
+		problems strings.Builder
+		counts   = map[string]int{}
+	)
+
+	summary, long, _ := strings.Cut(output, "\n")
+
+	for line := range strings.SplitSeq(long, "\n") {
+		m := subResultRe.FindStringSubmatch(line)
+		if m == nil {
+			// Keep the perfdata
+			if line != "" {
+				problems.WriteString("\n" + line)
+			}
+
+			continue
+		}
+
+		if m[1] == "" {
+			counts[m[2]]++
+		}
+
+		if m[2] != check.OKString {
+			problems.WriteString("\n" + line)
+		}
+	}
+
+	var total []string
+
+	for _, s := range []string{check.OKString, check.WarningString, check.CriticalString, check.UnknownString} {
+		if counts[s] > 0 {
+			total = append(total, fmt.Sprintf("%d %s", counts[s], s))
+		}
+	}
+
+	if len(total) > 0 {
+		summary = fmt.Sprintf("%s (%s)", summary, strings.Join(total, ", "))
+	}
+
+	return summary + problems.String()
+}
+
+// thresholdString returns the string representation of an optional threshold.
+func thresholdString(t *check.Threshold) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.String()
+}
+
+// jsonFloat omits values that cannot be encoded in JSON, e.g. a NaN percentage.
+func jsonFloat(v *float64) *float64 {
+	if v == nil || math.IsNaN(*v) || math.IsInf(*v, 0) {
+		return nil
+	}
+
+	return v
+}
+
+// jsonValue returns nil for perfdata values that cannot be encoded in JSON.
+func jsonValue(v any) any {
+	if f, ok := v.(float64); ok {
+		return jsonFloat(&f)
+	}
+
+	return v
+}
+
+// newJSONCheck converts a subcheck and its nested subchecks for the JSON output.
+func newJSONCheck(c *checkResult) jsonCheck {
+	j := jsonCheck{
+		Name:     c.name,
+		State:    c.GetStatus().String(),
+		Value:    jsonFloat(c.value),
+		Warning:  thresholdString(c.warn),
+		Critical: thresholdString(c.crit),
+		Message:  c.message,
+	}
+
+	for _, p := range c.perfdata {
+		j.Perfdata = append(j.Perfdata, jsonPerfdata{
+			Label:    p.Label,
+			Value:    jsonValue(p.Value),
+			Uom:      p.Uom,
+			Warning:  thresholdString(p.Warn),
+			Critical: thresholdString(p.Crit),
+			Min:      p.Min,
+			Max:      p.Max,
+		})
+	}
+
+	for _, sub := range c.subchecks {
+		j.Checks = append(j.Checks, newJSONCheck(sub))
+	}
+
+	return j
+}
+
+// renderJSON renders the overall result as JSON document.
+func renderJSON(o *checkOverall) ([]byte, error) {
+	rc := o.GetStatus()
+	summary, _, _ := strings.Cut(o.GetOutput(), "\n")
+
+	doc := jsonOutput{
+		State:    rc.String(),
+		ExitCode: int(rc),
+		Summary:  summary,
+		Checks:   make([]jsonCheck, 0, len(o.subchecks)),
+	}
+
+	for _, c := range o.subchecks {
+		doc.Checks = append(doc.Checks, newJSONCheck(c))
+	}
+
+	perfdata := o.Perfdata()
+	doc.Perfdata = perfdata.String()
+
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// outputOptions control how an overall result is rendered.
+type outputOptions struct {
+	format       string
+	onlyProblems bool
+	host         string
+	command      string
+}
+
+// renderOverall renders the overall result in the output format.
+func renderOverall(o *checkOverall, opts outputOptions) (string, error) {
+	switch opts.format {
+	case "json":
+		b, err := renderJSON(o)
+		if err != nil {
+			return "", err
+		}
+
+		return string(b) + "\n", nil
+	case "openmetrics":
+		return renderOpenMetrics(o, opts.host, opts.command), nil
+	}
+
+	output := strings.TrimSuffix(o.GetOutput(), "\n")
+
+	if opts.onlyProblems {
+		output = filterProblems(output)
+	}
+
+	return "[" + o.GetStatus().String() + "] - " + output + "\n", nil
+}
+
+// renderError renders the state and output of an error in the output format.
+func renderError(err error, opts outputOptions) (string, error) {
+	state, output := errorResult(err)
+
+	switch opts.format {
+	case "json":
+		b, err := json.MarshalIndent(jsonOutput{
+			State:    state.String(),
+			ExitCode: int(state),
+			Summary:  output,
+			Checks:   []jsonCheck{},
+		}, "", "  ")
+		if err != nil {
+			return "", err
+		}
+
+		return string(b) + "\n", nil
+	case "openmetrics":
+		m := newMetricSet()
+		m.addState(state, opts.host, opts.command)
+
+		return m.String(), nil
+	}
+
+	return "[" + state.String() + "] - " + output + "\n", nil
+}
+
+// cliOutputOptions returns the output options of the command line flags.
+func cliOutputOptions() outputOptions {
+	return outputOptions{
+		format:       cliConfig.Output,
+		onlyProblems: cliConfig.OnlyProblems,
+		host:         cliConfig.targetHost(),
+		command:      commandPath,
+	}
+}
+
+// exitOverall exits with the state and output of the result tree, after applying the output mode.
+func exitOverall(o *checkOverall) {
+	if r := cliConfig.certExpiryResult(); r != nil {
+		o.AddSubcheck(r)
+	}
+
+	output, err := renderOverall(o, cliOutputOptions())
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	// The Icinga 2 API receives the output without perfdata, which is
+	// the part after the last separator since go-check replaces it in the output
+	pluginOutput, _ := renderOverall(o, outputOptions{format: "icinga", onlyProblems: cliConfig.OnlyProblems})
+	if i := strings.LastIndex(pluginOutput, check.PerfdataSeparatorSymbol); i >= 0 {
+		pluginOutput = pluginOutput[:i]
+	}
+
+	exitResult(o.GetStatus(), output, strings.TrimSuffix(pluginOutput, "\n"), o.Perfdata())
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/NETWAYS/go-check"
+)
+
+func TestFilterProblems(t *testing.T) {
+	output := "inflight_events_b:50\n" +
+		"\\_ [OK] pipeline a\n    \\_ [OK] inflight_events_a:1\n" +
+		"\\_ [CRITICAL] pipeline b\n    \\_ [OK] events_out_rate_b:1.00/s\n    \\_ [CRITICAL] inflight_events_b:50\n" +
+		"\\_ [OK] pipeline c\n    \\_ [OK] inflight_events_c:2\n" +
+		"|inflight_events_a=1 inflight_events_b=50 inflight_events_c=2"
+
+	actual := filterProblems(output)
+
+	expected := "inflight_events_b:50 (2 OK, 1 CRITICAL)\n" +
+		"\\_ [CRITICAL] pipeline b\n    \\_ [CRITICAL] inflight_events_b:50\n" +
+		"|inflight_events_a=1 inflight_events_b=50 inflight_events_c=2"
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	actual = filterProblems("Logstash is healthy\n\\_ [OK] Heap usage at 12.00%")
+
+	expected = "Logstash is healthy (1 OK)"
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestRenderJSON(t *testing.T) {
+	var o checkOverall
+
+	warn, _ := check.ParseThreshold("10")
+	crit, _ := check.ParseThreshold("20")
+
+	r := newPipelineResult("example")
+	inflight := newThresholdResult("inflight_events", 15, warn, crit, "inflight_events_example:15")
+	inflight.AddPerfdata(&check.Perfdata{Label: "inflight_events_example", Warn: warn, Crit: crit, Value: 15})
+	r.partial.AddSubcheck(inflight)
+	r.partial.AddPerfdata(&check.Perfdata{Label: "pipelines.example.events.in", Uom: "c", Value: 100})
+	o.AddSubcheck(r.partial)
+
+	b, err := renderJSON(&o)
+	if err != nil {
+		t.Error(err)
+	}
+
+	var actual jsonOutput
+
+	err = json.Unmarshal(b, &actual)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if actual.State != "WARNING" || actual.ExitCode != 1 || actual.Summary != "inflight_events_example:15" {
+		t.Error("\nActual: ", actual.State, actual.ExitCode, actual.Summary, "\nExpected: ", "WARNING 1 inflight_events_example:15")
+	}
+
+	expected := "pipelines.example.events.in=100c inflight_events_example=15;10;20"
+	if actual.Perfdata != expected {
+		t.Error("\nActual: ", actual.Perfdata, "\nExpected: ", expected)
+	}
+
+	c := actual.Checks[0].Checks[0]
+	if c.Name != "inflight_events" || c.State != "WARNING" || *c.Value != 15 || c.Warning != "10" || c.Critical != "20" {
+		t.Error("\nActual: ", c, "\nExpected: ", "inflight_events WARNING 15 10 20")
+	}
+}
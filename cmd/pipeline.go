@@ -1,31 +1,82 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/check_logstash/internal/state"
 	"github.com/NETWAYS/go-check"
 	"github.com/spf13/cobra"
 )
 
 // PipelineConfig for the CLI parameters.
 type PipelineConfig struct {
-	PipelineName string
-	Warning      string
-	Critical     string
+	PipelineName        string
+	Warning             string
+	Critical            string
+	OutRateWarning      string
+	OutRateCritical     string
+	InRateWarning       string
+	InRateCritical      string
+	FilteredRateWarning string
+	FilteredRateCrit    string
+	PluginRateWarning   string
+	PluginRateCritical  string
+	FailureDeltaWarning string
+	FailureDeltaCrit    string
+	Interval            bool
+	StuckRuns           int
+	StuckDuration       time.Duration
+	ChangeWindow        time.Duration
+	ChangeState         int
+	Thresholds          []string
+	Include             []string
+	Exclude             []string
+	Regex               bool
+	SortBy              string
+	FailureMaxAge       time.Duration
+	FailureExpiredState int
+	SuccessMaxAge       time.Duration
+	SuccessMissingState int
 }
 
 // PipelineThreshold for the parsed CLI parameters.
 type PipelineThreshold struct {
+	Warning             *check.Threshold
+	Critical            *check.Threshold
+	OutRateWarning      *check.Threshold
+	OutRateCritical     *check.Threshold
+	InRateWarning       *check.Threshold
+	InRateCritical      *check.Threshold
+	FilteredRateWarning *check.Threshold
+	FilteredRateCrit    *check.Threshold
+	PluginRateWarning   *check.Threshold
+	PluginRateCritical  *check.Threshold
+	Overrides           []PipelineThresholdOverride
+}
+
+// PipelineThresholdOverride for the thresholds of pipelines matching a pattern.
+type PipelineThresholdOverride struct {
+	Matches  func(string) bool
 	Warning  *check.Threshold
 	Critical *check.Threshold
 }
 
+// For returns the thresholds of the given pipeline, the first matching
+// override is used and the global thresholds are the fallback.
+func (t PipelineThreshold) For(pipeline string) (*check.Threshold, *check.Threshold) {
+	for _, o := range t.Overrides {
+		if o.Matches(pipeline) {
+			return o.Warning, o.Critical
+		}
+	}
+
+	return t.Warning, t.Critical
+}
+
 var cliPipelineConfig PipelineConfig
 
 // calculateInflightEvents calculates the current inflight events,
@@ -40,6 +91,244 @@ func calculateInflightEvents(in, out int) int {
 	return r
 }
 
+// reloadFailureState returns the state for a failed configuration reload.
+// Failures older than maxAge are reported with the expired state,
+// a maxAge of 0 keeps every failure critical.
+func reloadFailureState(lastFailure, now time.Time, maxAge time.Duration, expired check.Status) check.Status {
+	if maxAge > 0 && now.Sub(lastFailure) > maxAge {
+		return expired
+	}
+
+	return check.Critical
+}
+
+// reloadHappenedWithin reports whether the last successful reload
+// happened within maxAge, an empty or invalid timestamp counts as no reload.
+func reloadHappenedWithin(lastSuccess string, now time.Time, maxAge time.Duration) bool {
+	lastSuccessReload, err := time.Parse(time.RFC3339, lastSuccess)
+	if err != nil {
+		return false
+	}
+
+	return now.Sub(lastSuccessReload) <= maxAge
+}
+
+// newReloadResult returns the subcheck of the last reload of a pipeline, the timestamps are
+// empty if they are null in the API. A failure without a successful reload is reported as failure.
+// Returns nil if the pipeline has never been reloaded.
+func newReloadResult(name, lastSuccess, lastFailure string, now time.Time, maxAge time.Duration, expired check.Status) *checkResult {
+	if lastSuccess == "" && lastFailure == "" {
+		return nil
+	}
+
+	var (
+		lastSuccessReload, lastFailureReload time.Time
+		errSu, errFa                         error
+	)
+
+	// We could do the parsing during the unmarshall
+	if lastSuccess != "" {
+		lastSuccessReload, errSu = time.Parse(time.RFC3339, lastSuccess)
+	}
+
+	if lastFailure != "" {
+		lastFailureReload, errFa = time.Parse(time.RFC3339, lastFailure)
+	}
+
+	switch {
+	case errSu != nil || errFa != nil:
+		return newStateResult("reload", check.Unknown, "Configuration reload for pipeline %s unknown", name)
+	case lastFailure != "" && (lastSuccess == "" || lastFailureReload.After(lastSuccessReload)):
+		failureState := reloadFailureState(lastFailureReload, now, maxAge, expired)
+
+		if failureState == check.Critical {
+			return newStateResult("reload", failureState,
+				"Configuration reload for pipeline %s failed on %s", name, lastFailureReload)
+		}
+
+		return newStateResult("reload", failureState,
+			"Configuration reload for pipeline %s failed on %s, more than %s ago", name, lastFailureReload, maxAge)
+	default:
+		return newStateResult("reload", check.OK,
+			"Configuration successfully reloaded for pipeline %s for on %s", name, lastSuccessReload)
+	}
+}
+
+// calculateDurationPerEvent calculates the average duration
+// in milliseconds per event, returns 0 if there were no events.
+func calculateDurationPerEvent(duration, events int) float64 {
+	if events <= 0 {
+		return 0
+	}
+
+	return float64(duration) / float64(events)
+}
+
+// trackStuckPipeline updates the stuck tracking of the current sample.
+// A pipeline counts as stuck while events.in keeps growing
+// but events.out has not changed since the previous sample.
+func trackStuckPipeline(prev state.Sample, cur *state.Sample) {
+	in, okIn := state.Delta(prev, *cur, "events.in")
+	out, okOut := state.Delta(prev, *cur, "events.out")
+
+	if !okIn || !okOut || in <= 0 || out > 0 {
+		cur.StuckRuns = 0
+		cur.StuckSince = time.Time{}
+
+		return
+	}
+
+	cur.StuckRuns = prev.StuckRuns + 1
+	cur.StuckSince = prev.StuckSince
+
+	if cur.StuckSince.IsZero() {
+		cur.StuckSince = prev.Timestamp
+	}
+}
+
+// isStuckPipeline reports whether a pipeline has been stuck for at least
+// the given number of runs or duration, a limit of 0 is ignored.
+func isStuckPipeline(cur state.Sample, runs int, duration time.Duration) bool {
+	if cur.StuckRuns == 0 {
+		return false
+	}
+
+	if runs > 0 && cur.StuckRuns >= runs {
+		return true
+	}
+
+	return duration > 0 && cur.Timestamp.Sub(cur.StuckSince) >= duration
+}
+
+// trackPipelineChanges records a restart or configuration change of the
+// pipeline in the current sample, by comparing it with the last sample.
+func trackPipelineChanges(last state.Sample, cur *state.Sample) {
+	cur.ChangedAt = last.ChangedAt
+	cur.Change = last.Change
+	cur.PreviousHash = last.PreviousHash
+
+	switch {
+	case last.Hash != "" && last.Hash != cur.Hash:
+		cur.ChangedAt = cur.Timestamp
+		cur.Change = "configuration changed"
+		cur.PreviousHash = last.Hash
+	case last.NodeEphemeralID != "" && last.NodeEphemeralID != cur.NodeEphemeralID:
+		cur.ChangedAt = cur.Timestamp
+		cur.Change = "Logstash restarted"
+		cur.PreviousHash = ""
+	case last.EphemeralID != "" && last.EphemeralID != cur.EphemeralID:
+		cur.ChangedAt = cur.Timestamp
+		cur.Change = "pipeline restarted"
+		cur.PreviousHash = ""
+	}
+}
+
+// pipelineChangeMessage describes the last change of a pipeline tracked by trackPipelineChanges,
+// with the previous hash only for configuration changes.
+func pipelineChangeMessage(name string, s state.Sample) string {
+	if s.PreviousHash != "" {
+		return fmt.Sprintf("Pipeline %s %s on %s (hash %s -> %s)", name, s.Change, s.ChangedAt.Round(time.Second), s.PreviousHash, s.Hash)
+	}
+
+	return fmt.Sprintf("Pipeline %s %s on %s (hash %s)", name, s.Change, s.ChangedAt.Round(time.Second), s.Hash)
+}
+
+// calculateIntervalDurationPerEvent calculates the average duration in milliseconds
+// per event between two samples, returns 0 if the counters have been reset.
+func calculateIntervalDurationPerEvent(prev, cur state.Sample, durationCounter, eventsCounter string) float64 {
+	duration, okDuration := state.Delta(prev, cur, durationCounter)
+	events, okEvents := state.Delta(prev, cur, eventsCounter)
+
+	if !okDuration || !okEvents || events <= 0 {
+		return 0
+	}
+
+	return duration / events
+}
+
+// newRateResult creates a subcheck for the per-second rate of a counter between two samples,
+// e.g. events_out_rate for events.out. The scope is the prefix of plugin counters, the label
+// prefix is the prefix of the perfdata. Returns false if the counter has been reset.
+func newRateResult(prev, cur state.Sample, scope, counter, id, labelPrefix string, warn, crit *check.Threshold) (*checkResult, bool) {
+	rate, ok := state.Rate(prev, cur, scope+counter)
+	if !ok {
+		return nil, false
+	}
+
+	name := strings.ReplaceAll(counter, ".", "_") + "_rate"
+
+	c := newThresholdResult(name, rate, warn, crit, "%s_%s:%.2f/s", name, id, rate)
+	c.AddPerfdata(&check.Perfdata{
+		Label: labelPrefix + "." + counter + "_rate",
+		Warn:  warn,
+		Crit:  crit,
+		Value: rate})
+
+	return c, true
+}
+
+// parseOptionalThreshold parses a threshold that may be omitted,
+// returns nil if the spec is empty.
+func parseOptionalThreshold(spec string) (*check.Threshold, error) {
+	if spec == "" {
+		return nil, nil //nolint: nilnil
+	}
+
+	return check.ParseThreshold(spec)
+}
+
+// violatesOptionalThreshold reports whether the value violates
+// the threshold, an omitted threshold is never violated.
+func violatesOptionalThreshold(t *check.Threshold, value float64) bool {
+	return t != nil && t.DoesViolate(value)
+}
+
+// parsePipeThresholdOverride parses a per-pipeline threshold override.
+// Format: pattern=warning:critical or pattern=warning,critical for range thresholds.
+// Without a comma only the first colon separates the thresholds, so only the critical
+// threshold may be a range, e.g. beats=100:200:500.
+func parsePipeThresholdOverride(spec string, regex bool) (PipelineThresholdOverride, error) {
+	var o PipelineThresholdOverride
+
+	pattern, thresholds, found := strings.Cut(spec, "=")
+	if !found || pattern == "" {
+		return o, fmt.Errorf("could not parse threshold override %s, use pattern=warning:critical", spec)
+	}
+
+	warning, critical, found := strings.Cut(thresholds, ",")
+	if !found {
+		warning, critical, found = strings.Cut(thresholds, ":")
+
+		// The colon of a warning range like @5:10 would be taken as the separator
+		if strings.HasPrefix(warning, "@") {
+			return o, fmt.Errorf("could not parse threshold override %s, use pattern=warning,critical for a warning range", spec)
+		}
+	}
+
+	if !found {
+		return o, fmt.Errorf("could not parse threshold override %s, use pattern=warning:critical", spec)
+	}
+
+	matches, err := newPipelineMatcher(pattern, regex)
+	if err != nil {
+		return o, err
+	}
+
+	o.Matches = matches
+
+	o.Warning, err = check.ParseThreshold(warning)
+	if err != nil {
+		return o, err
+	}
+
+	o.Critical, err = check.ParseThreshold(critical)
+	if err != nil {
+		return o, err
+	}
+
+	return o, nil
+}
+
 func parsePipeThresholds(config PipelineConfig) (PipelineThreshold, error) {
 	// Parses the CLI parameters
 	var t PipelineThreshold
@@ -58,124 +347,349 @@ func parsePipeThresholds(config PipelineConfig) (PipelineThreshold, error) {
 
 	t.Critical = crit
 
+	// Optional rate thresholds, only used with a state file
+	rates := []struct {
+		threshold **check.Threshold
+		spec      string
+	}{
+		{&t.OutRateWarning, config.OutRateWarning},
+		{&t.OutRateCritical, config.OutRateCritical},
+		{&t.InRateWarning, config.InRateWarning},
+		{&t.InRateCritical, config.InRateCritical},
+		{&t.FilteredRateWarning, config.FilteredRateWarning},
+		{&t.FilteredRateCrit, config.FilteredRateCrit},
+		{&t.PluginRateWarning, config.PluginRateWarning},
+		{&t.PluginRateCritical, config.PluginRateCritical},
+	}
+
+	for _, rate := range rates {
+		*rate.threshold, err = parseOptionalThreshold(rate.spec)
+		if err != nil {
+			return t, err
+		}
+	}
+
+	// Per-pipeline overrides of the thresholds
+	for _, spec := range config.Thresholds {
+		o, errOverride := parsePipeThresholdOverride(spec, config.Regex)
+		if errOverride != nil {
+			return t, errOverride
+		}
+
+		t.Overrides = append(t.Overrides, o)
+	}
+
 	return t, nil
 }
 
+// errChangesStateFile is returned by pipeline changes without a state file,
+// since the changes are detected by comparing with the previous check run.
+var errChangesStateFile = errors.New("checking pipeline changes requires a --state-file")
+
+// stateFileFlags returns the flags that compare with the previous check run.
+// Since the subcommands share the config, only the flags of the current subcommand can be set.
+func (pc PipelineConfig) stateFileFlags() []stateFileFlag {
+	return []stateFileFlag{
+		{"--stuck-runs", pc.StuckRuns > 0},
+		{"--stuck-duration", pc.StuckDuration > 0},
+		{"--events-out-rate-warn", pc.OutRateWarning != ""},
+		{"--events-out-rate-crit", pc.OutRateCritical != ""},
+		{"--events-in-rate-warn", pc.InRateWarning != ""},
+		{"--events-in-rate-crit", pc.InRateCritical != ""},
+		{"--events-filtered-rate-warn", pc.FilteredRateWarning != ""},
+		{"--events-filtered-rate-crit", pc.FilteredRateCrit != ""},
+		{"--plugin-events-out-rate-warn", pc.PluginRateWarning != ""},
+		{"--plugin-events-out-rate-crit", pc.PluginRateCritical != ""},
+		{"--failure-delta-warn", pc.FailureDeltaWarning != ""},
+		{"--failure-delta-crit", pc.FailureDeltaCrit != ""},
+		{"--interval", pc.Interval},
+	}
+}
+
+// evaluatePipeline evaluates the inflight events of the pipelines.
+func evaluatePipeline(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall    checkOverall
+		pp         logstash.Pipeline
+		thresholds PipelineThreshold
+	)
+
+	// Parse the thresholds into a central var since we need them later
+	thresholds, err := parsePipeThresholds(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = requireStateFile(cfg, pc.stateFileFlags()...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeState, err := loadPipelineState(cfg, "pipeline")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	// Check status for each pipeline
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
+
+	for name, pipe := range pp.Pipelines {
+		r := newPipelineResult(name)
+		results = append(results, r)
+
+		warn, crit := thresholds.For(name)
+
+		inflightEvents := calculateInflightEvents(pipe.Events.In, pipe.Events.Out)
+		r.value = float64(inflightEvents)
+
+		inflight := newThresholdResult("inflight_events", float64(inflightEvents), warn, crit, "inflight_events_%s:%d", name, inflightEvents)
+		inflight.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("inflight_events_%s", name), //nolint: perfsprint
+			Warn:  warn,
+			Crit:  crit,
+			Value: inflightEvents})
+		r.partial.AddSubcheck(inflight)
+
+		// Generate perfdata for each event
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.events.in", name),
+			Uom:   "c",
+			Value: pipe.Events.In})
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.events.out", name),
+			Uom:   "c",
+			Value: pipe.Events.Out})
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.reloads.failures", name),
+			Value: pipe.Reloads.Failures})
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.reloads.successes", name),
+			Value: pipe.Reloads.Successes})
+
+		// Rates between the previous and the current check run
+		sample := newPipelineSample(pipe, now)
+
+		if prev, ok := pipeState.Previous(name, sample); ok {
+			// Detect pipelines that receive events but send none out
+			trackStuckPipeline(prev, &sample)
+
+			stuckFor := time.Duration(0)
+			if sample.StuckRuns > 0 {
+				stuckFor = sample.Timestamp.Sub(sample.StuckSince).Round(time.Second)
+			}
+
+			stuckPerfdata := &check.Perfdata{
+				Label: fmt.Sprintf("pipelines.%s.stuck_duration", name),
+				Uom:   "s",
+				Value: stuckFor.Seconds()}
+
+			switch {
+			case isStuckPipeline(sample, pc.StuckRuns, pc.StuckDuration):
+				stuck := newStateResult("stuck", check.Critical, "Pipeline %s stuck for %s (%d runs without events out)", name, stuckFor, sample.StuckRuns)
+				stuck.AddPerfdata(stuckPerfdata)
+				r.partial.AddSubcheck(stuck)
+			case sample.StuckRuns > 0:
+				stuck := newStateResult("stuck", check.OK, "Pipeline %s without events out for %s (%d runs)", name, stuckFor, sample.StuckRuns)
+				stuck.AddPerfdata(stuckPerfdata)
+				r.partial.AddSubcheck(stuck)
+			default:
+				r.partial.AddPerfdata(stuckPerfdata)
+			}
+
+			if rate, okRate := newRateResult(prev, sample, "", "events.out", name, "pipelines."+name,
+				thresholds.OutRateWarning, thresholds.OutRateCritical); okRate {
+				r.partial.AddSubcheck(rate)
+			}
+
+			if rate, okRate := newRateResult(prev, sample, "", "events.in", name, "pipelines."+name,
+				thresholds.InRateWarning, thresholds.InRateCritical); okRate {
+				r.partial.AddSubcheck(rate)
+			}
+
+			if rate, okRate := newRateResult(prev, sample, "", "events.filtered", name, "pipelines."+name,
+				thresholds.FilteredRateWarning, thresholds.FilteredRateCrit); okRate {
+				r.partial.AddSubcheck(rate)
+			}
+
+			// The events out per second of each plugin
+			for _, plugins := range [][]logstash.Plugin{pipe.Plugins.Inputs, pipe.Plugins.Filters, pipe.Plugins.Outputs} {
+				for _, plugin := range plugins {
+					rate, okRate := newRateResult(prev, sample, "plugins."+plugin.ID+".", "events.out", plugin.ID,
+						"pipelines."+name+".plugins."+plugin.ID, thresholds.PluginRateWarning, thresholds.PluginRateCritical)
+					if !okRate {
+						continue
+					}
+
+					p := newPluginResult(plugin)
+					p.AddSubcheck(rate)
+					r.partial.AddSubcheck(p)
+				}
+			}
+		}
+
+		pipeState.Update(name, sample)
+	}
+
+	addPipelineResults(&overall, results, pc.SortBy, "Inflight events status unknown")
+
+	err = pipeState.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	overall.SetOKSummary("Inflight events alright")
+
+	return &overall, nil
+}
+
 var pipelineCmd = &cobra.Command{
 	Use:   "pipeline",
 	Short: "Checks the status of the Logstash Pipelines",
 	Long:  `Checks the status of the Logstash Pipelines`,
 	Example: `
 	$ check_logstash pipeline --inflight-events-warn 5 --inflight-events-crit 10
-	WARNING - Inflight events
-	 \_[WARNING] inflight_events_example-input:9;
-	 \_[OK] inflight_events_example-default-connector:4
+	[WARNING] - inflight_events_example-input:9
+	\_ [OK] pipeline example-default-connector
+	    \_ [OK] inflight_events_example-default-connector:4
+	\_ [WARNING] pipeline example-input
+	    \_ [WARNING] inflight_events_example-input:9
 
 	$ check_logstash pipeline --inflight-events-warn 5 --inflight-events-crit 10 --pipeline example
-	CRITICAL - Inflight events
-	 \_[CRITICAL] inflight_events_example:15`,
+	[CRITICAL] - inflight_events_example:15
+	\_ [CRITICAL] pipeline example
+	    \_ [CRITICAL] inflight_events_example:15
+
+	$ check_logstash pipeline --inflight-events-warn 5 --inflight-events-crit 10 --state-file /tmp/example.json --stuck-runs 3
+	[CRITICAL] - Pipeline example stuck for 15m0s (3 runs without events out)
+	\_ [CRITICAL] pipeline example
+	    \_ [OK] inflight_events_example:4
+	    \_ [CRITICAL] Pipeline example stuck for 15m0s (3 runs without events out)
+	    \_ [OK] events_out_rate_example:0.00/s
+	    \_ [OK] events_in_rate_example:1.20/s
+	    \_ [OK] events_filtered_rate_example:0.00/s
+	    \_ [OK] plugin beats-input (beats)
+	        \_ [OK] events_out_rate_beats-input:0.00/s`,
 	Run: func(_ *cobra.Command, _ []string) {
-		var (
-			output     string
-			rc         check.Status
-			pp         logstash.Pipeline
-			thresholds PipelineThreshold
-			perfList   check.PerfdataList
-		)
-
-		// Parse the thresholds into a central var since we need them later
-		thresholds, err := parsePipeThresholds(cliPipelineConfig)
+		overall, err := evaluatePipeline(&cliConfig, cliPipelineConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		// Creating an client and connecting to the API
-		c := cliConfig.NewClient()
-		// localhost:9600/_node/stats/pipelines/ will return all Pipelines
-		// localhost:9600/_node/stats/pipelines/foo will return the foo Pipeline
-		u, _ := url.JoinPath(c.URL, "/_node/stats/pipelines", cliPipelineConfig.PipelineName)
+		exitOverall(overall)
+	},
+}
 
-		resp, err := c.Client.Get(u)
-		if err != nil {
-			check.ExitError(err)
-		}
+// evaluatePipelineReload evaluates the configuration reload status of the pipelines.
+func evaluatePipelineReload(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall checkOverall
+		pp      logstash.Pipeline
+	)
 
-		if resp.StatusCode != http.StatusOK {
-			check.ExitError(fmt.Errorf("could not get %s - Error: %d", u, resp.StatusCode))
-		}
+	failureExpiredState, err := check.NewStatus(pc.FailureExpiredState)
+	if err != nil {
+		return nil, err
+	}
 
-		defer resp.Body.Close()
+	successMissingState, err := check.NewStatus(pc.SuccessMissingState)
+	if err != nil {
+		return nil, err
+	}
 
-		err = json.NewDecoder(resp.Body).Decode(&pp)
-		if err != nil {
-			check.ExitError(err)
-		}
+	failureDeltaWarn, err := parseOptionalThreshold(pc.FailureDeltaWarning)
+	if err != nil {
+		return nil, err
+	}
 
-		states := make([]check.Status, 0, len(pp.Pipelines))
+	failureDeltaCrit, err := parseOptionalThreshold(pc.FailureDeltaCrit)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check status for each pipeline
-		var summary strings.Builder
+	err = requireStateFile(cfg, pc.stateFileFlags()...)
+	if err != nil {
+		return nil, err
+	}
 
-		for name, pipe := range pp.Pipelines {
-			inflightEvents := calculateInflightEvents(pipe.Events.In, pipe.Events.Out)
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
 
-			summary.WriteString("\n \\_")
+	// Check the reload configuration status for each pipeline
+	pipeState, err := loadPipelineState(cfg, "pipeline reload")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
-			if thresholds.Critical.DoesViolate(float64(inflightEvents)) {
-				states = append(states, check.Critical)
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
 
-				fmt.Fprintf(&summary, "[CRITICAL] inflight_events_%s:%d;", name, inflightEvents)
-			} else if thresholds.Warning.DoesViolate(float64(inflightEvents)) {
-				states = append(states, check.Warning)
+	for name, pipe := range pp.Pipelines {
+		var checks []*checkResult
 
-				fmt.Fprintf(&summary, "[WARNING] inflight_events_%s:%d;", name, inflightEvents)
-			} else {
-				states = append(states, check.OK)
+		// Check the reload failures since the previous check run,
+		// which are the value to sort by, or the lifetime failures without a previous sample
+		sample := newPipelineSample(pipe, now)
+		failures := float64(pipe.Reloads.Failures)
 
-				fmt.Fprintf(&summary, "[OK] inflight_events_%s:%d;", name, inflightEvents)
+		if prev, ok := pipeState.Previous(name, sample); ok {
+			if delta, okDelta := state.Delta(prev, sample, "reloads.failures"); okDelta {
+				failures = delta
+				checks = append(checks, newThresholdResult("reload_failures", failures, failureDeltaWarn, failureDeltaCrit,
+					"%.0f configuration reload failures for pipeline %s since last check", failures, name))
 			}
+		}
+
+		pipeState.Update(name, sample)
 
-			// Generate perfdata for each event
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.events.in", name),
-				Uom:   "c",
-				Value: pipe.Events.In})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.events.out", name),
-				Uom:   "c",
-				Value: pipe.Events.Out})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("inflight_events_%s", name), //nolint: perfsprint
-				Warn:  thresholds.Warning,
-				Crit:  thresholds.Critical,
-				Value: inflightEvents})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.reloads.failures", name),
-				Value: pipe.Reloads.Failures})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.reloads.successes", name),
-				Value: pipe.Reloads.Successes})
+		// Check that a reload happened within the expected period
+		if pc.SuccessMaxAge > 0 {
+			if reloadHappenedWithin(pipe.Reloads.LastSuccessTime, now, pc.SuccessMaxAge) {
+				checks = append(checks, newStateResult("reload_success", check.OK,
+					"Configuration for pipeline %s reloaded within %s", name, pc.SuccessMaxAge))
+			} else {
+				checks = append(checks, newStateResult("reload_success", successMissingState,
+					"No configuration reload for pipeline %s within %s", name, pc.SuccessMaxAge))
+			}
 		}
 
-		// Validate the various subchecks and use the worst state as return code
-		//nolint: exhaustive
-		switch check.WorstState(states...) {
-		case 0:
-			rc = check.OK
-			output = "Inflight events alright"
-		case 1:
-			rc = check.Warning
-			output = "Inflight events may not be alright"
-		case 2:
-			rc = check.Critical
-			output = "Inflight events not alright"
-		default:
-			rc = check.Unknown
-			output = "Inflight events status unknown"
+		// Check Reload Timestamp
+		if reload := newReloadResult(name, pipe.Reloads.LastSuccessTime, pipe.Reloads.LastFailureTime,
+			now, pc.FailureMaxAge, failureExpiredState); reload != nil {
+			checks = append(checks, reload)
 		}
 
-		check.ExitWithPerfdata(rc, perfList, output, summary.String())
-	},
+		// Pipelines without any reload information are skipped
+		if len(checks) == 0 {
+			continue
+		}
+
+		r := newPipelineResult(name)
+		r.value = failures
+		results = append(results, r)
+
+		for _, c := range checks {
+			r.partial.AddSubcheck(c)
+		}
+	}
+
+	addPipelineResults(&overall, results, pc.SortBy, "Configuration reload status unknown")
+
+	err = pipeState.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	overall.SetOKSummary("Configuration successfully reloaded")
+
+	return &overall, nil
 }
 
 var pipelineReloadCmd = &cobra.Command{
@@ -184,211 +698,477 @@ var pipelineReloadCmd = &cobra.Command{
 	Long:  `Checks the reload configuration status of the Logstash Pipelines`,
 	Example: `
 	$ check_logstash pipeline reload
-	OK - Configuration successfully reloaded
-	 \_[OK] Configuration successfully reloaded for pipeline Foobar for on 2021-01-01T02:07:14Z
+	[OK] - Configuration successfully reloaded
+	\_ [OK] pipeline Foobar
+	    \_ [OK] Configuration successfully reloaded for pipeline Foobar for on 2021-01-01T02:07:14Z
 
 	$ check_logstash pipeline reload --pipeline Example
-	CRITICAL - Configuration reload failed
-	 \_[CRITICAL] Configuration reload for pipeline Example failed on 2021-01-01T02:07:14Z`,
+	[CRITICAL] - Configuration reload for pipeline Example failed on 2021-01-01T02:07:14Z
+	\_ [CRITICAL] pipeline Example
+	    \_ [CRITICAL] Configuration reload for pipeline Example failed on 2021-01-01T02:07:14Z
+
+	$ check_logstash pipeline reload --failure-max-age 1h --success-max-age 24h
+	[WARNING] - Configuration reload for pipeline Example failed on 2021-01-01T02:07:14Z, more than 1h0m0s ago
+	\_ [WARNING] pipeline Example
+	    \_ [OK] Configuration for pipeline Example reloaded within 24h0m0s
+	    \_ [WARNING] Configuration reload for pipeline Example failed on 2021-01-01T02:07:14Z, more than 1h0m0s ago`,
 	Run: func(_ *cobra.Command, _ []string) {
-		var (
-			output string
-			rc     check.Status
-			pp     logstash.Pipeline
-		)
-
-		// Creating an client and connecting to the API
-		c := cliConfig.NewClient()
-		// localhost:9600/_node/stats/pipelines/ will return all Pipelines
-		// localhost:9600/_node/stats/pipelines/foo will return the foo Pipeline
-		u, _ := url.JoinPath(c.URL, "/_node/stats/pipelines", cliPipelineConfig.PipelineName)
-
-		resp, err := c.Client.Get(u)
+		overall, err := evaluatePipelineReload(&cliConfig, cliPipelineConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			check.ExitError(fmt.Errorf("could not get %s - Error: %d", u, resp.StatusCode))
-		}
+		exitOverall(overall)
+	},
+}
 
-		defer resp.Body.Close()
+// evaluatePipelineFlow evaluates the flow metrics of the pipelines.
+func evaluatePipelineFlow(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall    checkOverall
+		thresholds PipelineThreshold
+		pp         logstash.Pipeline
+	)
 
-		err = json.NewDecoder(resp.Body).Decode(&pp)
+	// Parse the thresholds into a central var since we need them later
+	thresholds, err := parsePipeThresholds(pc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the flow metrics for each pipeline
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
+
+	for name, pipe := range pp.Pipelines {
+		r := newPipelineResult(name)
+		results = append(results, r)
+
+		warn, crit := thresholds.For(name)
+
+		r.value = pipe.Flow.QueueBackpressure.Current
+
+		backpressure := newThresholdResult("queue_backpressure", pipe.Flow.QueueBackpressure.Current, warn, crit,
+			"queue_backpressure_%s:%.2f", name, pipe.Flow.QueueBackpressure.Current)
+		backpressure.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.queue_backpressure_%s", name), //nolint: perfsprint
+			Warn:  warn,
+			Crit:  crit,
+			Value: pipe.Flow.QueueBackpressure.Current})
+		r.partial.AddSubcheck(backpressure)
+
+		// Generate perfdata for each flow metric
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.output_throughput", name),
+			Value: pipe.Flow.OutputThroughput.Current})
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.input_throughput", name),
+			Value: pipe.Flow.InputThroughput.Current})
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.filter_throughput", name),
+			Value: pipe.Flow.FilterThroughput.Current})
+	}
+
+	addPipelineResults(&overall, results, pc.SortBy, "Flow metrics status unknown")
+
+	overall.SetOKSummary("Flow metrics alright")
+
+	return &overall, nil
+}
+
+var pipelineFlowCmd = &cobra.Command{
+	Use:   "flow",
+	Short: "Checks the flow metrics of the Logstash Pipelines",
+	Long:  `Checks the flow metrics of the Logstash Pipelines`,
+	Example: `
+	$ check_logstash pipeline flow --warning 5 --critical 10
+	[OK] - Flow metrics alright
+	\_ [OK] pipeline example
+	    \_ [OK] queue_backpressure_example:0.34
+
+	$ check_logstash pipeline flow --pipeline example --warning 5 --critical 10
+	[CRITICAL] - queue_backpressure_example:11.23
+	\_ [CRITICAL] pipeline example
+	    \_ [CRITICAL] queue_backpressure_example:11.23`,
+	Run: func(_ *cobra.Command, _ []string) {
+		overall, err := evaluatePipelineFlow(&cliConfig, cliPipelineConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		states := make([]check.Status, 0, len(pp.Pipelines))
+		exitOverall(overall)
+	},
+}
+
+// evaluatePipelineLatency evaluates the average event latency of the pipelines.
+func evaluatePipelineLatency(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall    checkOverall
+		thresholds PipelineThreshold
+		pp         logstash.Pipeline
+	)
 
-		// Check the reload configuration status for each pipeline
-		var summary strings.Builder
+	// Parse the thresholds into a central var since we need them later
+	thresholds, err := parsePipeThresholds(pc)
+	if err != nil {
+		return nil, err
+	}
 
-		for name, pipe := range pp.Pipelines {
-			// Check Reload Timestamp
-			if pipe.Reloads.LastSuccessTime != "" {
-				// We could do the parsing during the unmarshall
-				lastSuccessReload, errSu := time.Parse(time.RFC3339, pipe.Reloads.LastSuccessTime)
-				lastFailureReload, errFa := time.Parse(time.RFC3339, pipe.Reloads.LastFailureTime)
+	err = requireStateFile(cfg, pc.stateFileFlags()...)
+	if err != nil {
+		return nil, err
+	}
 
-				if errSu != nil || errFa != nil {
-					states = append(states, check.Unknown)
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
 
-					fmt.Fprintf(&summary, "[UNKNOWN] Configuration reload for pipeline %s unknown;", name)
-					summary.WriteString("\n  \\_")
+	// Check the average event latency for each pipeline
+	pipeState, err := loadPipelineState(cfg, "pipeline latency")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
-					continue
-				}
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
+
+	for name, pipe := range pp.Pipelines {
+		r := newPipelineResult(name)
+		results = append(results, r)
 
-				summary.WriteString("\n  \\_")
+		warn, crit := thresholds.For(name)
 
-				if lastFailureReload.After(lastSuccessReload) {
-					states = append(states, check.Critical)
+		// Use the increase since the previous check run instead of the lifetime values
+		sample := newPipelineSample(pipe, now)
+		prev, okPrev := pipeState.Previous(name, sample)
+		interval := okPrev && pc.Interval
 
-					fmt.Fprintf(&summary, "[CRITICAL] Configuration reload for pipeline %s failed on %s;", name, lastFailureReload)
-				} else {
-					states = append(states, check.OK)
+		latency := calculateDurationPerEvent(pipe.Events.Duration, pipe.Events.Out)
+		if interval {
+			latency = calculateIntervalDurationPerEvent(prev, sample, "events.duration_in_millis", "events.out")
+		}
+
+		r.value = latency
+
+		pipeLatency := newThresholdResult("event_latency", latency, warn, crit, "event_latency_%s:%.2fms", name, latency)
+		pipeLatency.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.event_latency_%s", name), //nolint: perfsprint
+			Uom:   "ms",
+			Warn:  warn,
+			Crit:  crit,
+			Value: latency})
+		r.partial.AddSubcheck(pipeLatency)
+
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.events.duration_in_millis", name),
+			Uom:   "c",
+			Value: pipe.Events.Duration})
+
+		// The latency of each plugin, for information only
+		for _, plugins := range [][]logstash.Plugin{pipe.Plugins.Inputs, pipe.Plugins.Filters, pipe.Plugins.Outputs} {
+			for _, plugin := range plugins {
+				// Input plugins have no duration, plugins without events have no latency yet
+				if plugin.Events.Duration == 0 || plugin.Events.Out == 0 {
+					continue
+				}
 
-					fmt.Fprintf(&summary, "[OK] Configuration successfully reloaded for pipeline %s for on %s;", name, lastSuccessReload)
+				pluginLatency := calculateDurationPerEvent(plugin.Events.Duration, plugin.Events.Out)
+				if interval {
+					pluginLatency = calculateIntervalDurationPerEvent(prev, sample,
+						"plugins."+plugin.ID+".events.duration_in_millis", "plugins."+plugin.ID+".events.out")
 				}
+
+				metric := newThresholdResult("event_latency", pluginLatency, nil, nil, "event_latency_%s:%.2fms", plugin.ID, pluginLatency)
+				metric.AddPerfdata(&check.Perfdata{
+					Label: fmt.Sprintf("pipelines.%s.plugins.%s.event_latency", name, plugin.ID),
+					Uom:   "ms",
+					Value: pluginLatency})
+
+				p := newPluginResult(plugin)
+				p.AddSubcheck(metric)
+				r.partial.AddSubcheck(p)
 			}
 		}
 
-		// Validate the various subchecks and use the worst state as return code
-		//nolint: exhaustive
-		switch check.WorstState(states...) {
-		case 0:
-			rc = check.OK
-			output = "Configuration successfully reloaded"
-		case 1:
-			rc = check.Warning
-			output = "Configuration reload may not be successful"
-		case 2:
-			rc = check.Critical
-			output = "Configuration reload failed"
-		default:
-			rc = check.Unknown
-			output = "Configuration reload status unknown"
-		}
+		pipeState.Update(name, sample)
+	}
 
-		check.Exit(rc, output, summary.String())
-	},
+	addPipelineResults(&overall, results, pc.SortBy, "Event latency status unknown")
+
+	err = pipeState.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	overall.SetOKSummary("Event latency alright")
+
+	return &overall, nil
 }
 
-var pipelineFlowCmd = &cobra.Command{
-	Use:   "flow",
-	Short: "Checks the flow metrics of the Logstash Pipelines",
-	Long:  `Checks the flow metrics of the Logstash Pipelines`,
+var pipelineLatencyCmd = &cobra.Command{
+	Use:   "latency",
+	Short: "Checks the average event latency of the Logstash Pipelines",
+	Long: `Checks the average event latency of the Logstash Pipelines.
+The latency is calculated as milliseconds per event: events.duration_in_millis / events.out
+The latency of each plugin is shown for information, only the pipeline latency is checked against the thresholds`,
 	Example: `
-	$ check_logstash pipeline flow --warning 5 --critical 10
-	OK - Flow metrics alright
-	 \_[OK] queue_backpressure_example:0.34;
-
-	$ check_logstash pipeline flow --pipeline example --warning 5 --critical 10
-	CRITICAL - Flow metrics not alright
-	 \_[CRITICAL] queue_backpressure_example:11.23;`,
+	$ check_logstash pipeline latency --warning 50 --critical 100
+	[OK] - Event latency alright
+	\_ [OK] pipeline example
+	    \_ [OK] event_latency_example:1.25ms
+	    \_ [OK] plugin example-input (beats)
+	        \_ [OK] event_latency_example-input:0.50ms
+
+	$ check_logstash pipeline latency --pipeline example --warning 50 --critical 100
+	[CRITICAL] - event_latency_example:123.45ms
+	\_ [CRITICAL] pipeline example
+	    \_ [CRITICAL] event_latency_example:123.45ms
+	    \_ [OK] plugin example-input (beats)
+	        \_ [OK] event_latency_example-input:0.50ms`,
 	Run: func(_ *cobra.Command, _ []string) {
-		var (
-			output     string
-			rc         check.Status
-			thresholds PipelineThreshold
-			pp         logstash.Pipeline
-			perfList   check.PerfdataList
-		)
-
-		// Parse the thresholds into a central var since we need them later
-		thresholds, err := parsePipeThresholds(cliPipelineConfig)
+		overall, err := evaluatePipelineLatency(&cliConfig, cliPipelineConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		// Creating an client and connecting to the API
-		c := cliConfig.NewClient()
-		// localhost:9600/_node/stats/pipelines/ will return all Pipelines
-		// localhost:9600/_node/stats/pipelines/foo will return the foo Pipeline
-		u, _ := url.JoinPath(c.URL, "/_node/stats/pipelines", cliPipelineConfig.PipelineName)
+		exitOverall(overall)
+	},
+}
+
+// evaluatePipelineBackpressure evaluates the queue push duration of the pipelines.
+func evaluatePipelineBackpressure(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall    checkOverall
+		thresholds PipelineThreshold
+		pp         logstash.Pipeline
+	)
 
-		resp, err := c.Client.Get(u)
-		if err != nil {
-			check.ExitError(err)
-		}
+	// Parse the thresholds into a central var since we need them later
+	thresholds, err := parsePipeThresholds(pc)
+	if err != nil {
+		return nil, err
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			check.ExitError(fmt.Errorf("could not get %s - Error: %d", u, resp.StatusCode))
+	err = requireStateFile(cfg, pc.stateFileFlags()...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the queue push duration for each pipeline
+	pipeState, err := loadPipelineState(cfg, "pipeline backpressure")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
+
+	for name, pipe := range pp.Pipelines {
+		r := newPipelineResult(name)
+		results = append(results, r)
+
+		warn, crit := thresholds.For(name)
+
+		pushDuration := calculateDurationPerEvent(pipe.Events.QueuePushDuration, pipe.Events.In)
+
+		// Use the increase since the previous check run instead of the lifetime values
+		sample := newPipelineSample(pipe, now)
+
+		if prev, ok := pipeState.Previous(name, sample); ok && pc.Interval {
+			pushDuration = calculateIntervalDurationPerEvent(prev, sample, "events.queue_push_duration_in_millis", "events.in")
 		}
 
-		defer resp.Body.Close()
+		pipeState.Update(name, sample)
+
+		r.value = pushDuration
+
+		push := newThresholdResult("queue_push_duration", pushDuration, warn, crit, "queue_push_duration_%s:%.2fms", name, pushDuration)
+		push.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.queue_push_duration_%s", name), //nolint: perfsprint
+			Uom:   "ms",
+			Warn:  warn,
+			Crit:  crit,
+			Value: pushDuration})
+		r.partial.AddSubcheck(push)
 
-		err = json.NewDecoder(resp.Body).Decode(&pp)
+		r.partial.AddPerfdata(&check.Perfdata{
+			Label: fmt.Sprintf("pipelines.%s.events.queue_push_duration_in_millis", name),
+			Uom:   "c",
+			Value: pipe.Events.QueuePushDuration})
+	}
+
+	addPipelineResults(&overall, results, pc.SortBy, "Queue push duration status unknown")
+
+	err = pipeState.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	overall.SetOKSummary("Queue push duration alright")
+
+	return &overall, nil
+}
+
+var pipelineBackpressureCmd = &cobra.Command{
+	Use:   "backpressure",
+	Short: "Checks the queue push duration per event of the Logstash Pipelines",
+	Long: `Checks the queue push duration per event of the Logstash Pipelines.
+The push duration is calculated as milliseconds per incoming event: events.queue_push_duration_in_millis / events.in
+This can be used as an approximation of the queue backpressure for Logstash versions before 8.5`,
+	Example: `
+	$ check_logstash pipeline backpressure --warning 5 --critical 10
+	[OK] - Queue push duration alright
+	\_ [OK] pipeline example
+	    \_ [OK] queue_push_duration_example:0.12ms
+
+	$ check_logstash pipeline backpressure --pipeline example --warning 5 --critical 10
+	[CRITICAL] - queue_push_duration_example:11.23ms
+	\_ [CRITICAL] pipeline example
+	    \_ [CRITICAL] queue_push_duration_example:11.23ms`,
+	Run: func(_ *cobra.Command, _ []string) {
+		overall, err := evaluatePipelineBackpressure(&cliConfig, cliPipelineConfig)
 		if err != nil {
-			check.ExitError(err)
+			exitError(err)
 		}
 
-		states := make([]check.Status, 0, len(pp.Pipelines))
+		exitOverall(overall)
+	},
+}
+
+// evaluatePipelineChanges evaluates the restarts and configuration changes of the pipelines.
+func evaluatePipelineChanges(cfg *Config, pc PipelineConfig) (*checkOverall, error) {
+	var (
+		overall checkOverall
+		pp      logstash.Pipeline
+	)
+
+	changeState, err := check.NewStatus(pc.ChangeState)
+	if err != nil {
+		return nil, err
+	}
 
-		// Check the flow metrics for each pipeline
-		var summary strings.Builder
+	pipeState, err := loadPipelineState(cfg, "pipeline changes")
+	if err != nil {
+		return nil, err
+	}
+	if pipeState == nil {
+		return nil, errChangesStateFile
+	}
 
-		for name, pipe := range pp.Pipelines {
-			summary.WriteString("\n \\_")
+	err = fetchPipelines(cfg, pc, &pp)
+	if err != nil {
+		return nil, err
+	}
 
-			if thresholds.Critical.DoesViolate(pipe.Flow.QueueBackpressure.Current) {
-				states = append(states, check.Critical)
+	// Check for restarts and configuration changes of each pipeline
+	now := time.Now()
 
-				fmt.Fprintf(&summary, "[CRITICAL] queue_backpressure_%s:%.2f;", name, pipe.Flow.QueueBackpressure.Current)
-			} else if thresholds.Warning.DoesViolate(pipe.Flow.QueueBackpressure.Current) {
-				states = append(states, check.Warning)
+	results := make([]*pipelineResult, 0, len(pp.Pipelines))
 
-				fmt.Fprintf(&summary, "[WARNING] queue_backpressure_%s:%.2f;", name, pipe.Flow.QueueBackpressure.Current)
-			} else {
-				states = append(states, check.OK)
+	for name, pipe := range pp.Pipelines {
+		r := newPipelineResult(name)
+		results = append(results, r)
 
-				fmt.Fprintf(&summary, "[OK] queue_backpressure_%s:%.2f;", name, pipe.Flow.QueueBackpressure.Current)
-			}
+		sample := newPipelineSample(pipe, now)
+		sample.NodeEphemeralID = pp.EphemeralID
 
-			// Generate perfdata for each event
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.queue_backpressure_%s", name), //nolint: perfsprint
-				Warn:  thresholds.Warning,
-				Crit:  thresholds.Critical,
-				Value: pipe.Flow.QueueBackpressure.Current})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.output_throughput", name),
-				Value: pipe.Flow.OutputThroughput.Current})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.input_throughput", name),
-				Value: pipe.Flow.InputThroughput.Current})
-			perfList.Add(&check.Perfdata{
-				Label: fmt.Sprintf("pipelines.%s.filter_throughput", name),
-				Value: pipe.Flow.FilterThroughput.Current})
+		if last, ok := pipeState.Last(name); ok {
+			trackPipelineChanges(last, &sample)
 		}
 
-		// Validate the various subchecks and use the worst state as return code
-		//nolint: exhaustive
-		switch check.WorstState(states...) {
-		case 0:
-			rc = check.OK
-			output = "Flow metrics alright"
-		case 1:
-			rc = check.Warning
-			output = "Flow metrics may not be alright"
-		case 2:
-			rc = check.Critical
-			output = "Flow metrics not alright"
-		default:
-			rc = check.Unknown
-			output = "Flow metrics status unknown"
+		pipeState.Update(name, sample)
+
+		if sample.Change == "" {
+			// Pipelines without changes are sorted last by value
+			r.partial.AddSubcheck(newStateResult("changes", check.OK, "Pipeline %s running with hash %s", name, sample.Hash))
+
+			continue
+		}
+
+		// The most recent change is sorted first by value
+		r.value = float64(sample.ChangedAt.Unix())
+
+		changeStatus := check.OK
+		if now.Sub(sample.ChangedAt) <= pc.ChangeWindow {
+			changeStatus = changeState
+		}
+
+		r.partial.AddSubcheck(newStateResult("changes", changeStatus, "%s", pipelineChangeMessage(name, sample)))
+	}
+
+	addPipelineResults(&overall, results, pc.SortBy, "Pipeline changes unknown")
+
+	err = pipeState.Save()
+	if err != nil {
+		return nil, err
+	}
+
+	overall.SetOKSummary("No pipeline changes")
+
+	return &overall, nil
+}
+
+var pipelineChangesCmd = &cobra.Command{
+	Use:   "changes",
+	Short: "Checks for restarts and configuration changes of the Logstash Pipelines",
+	Long: `Checks for restarts and configuration changes of the Logstash Pipelines.
+The pipeline hash and ephemeral IDs are remembered in the state file, a restart or configuration change
+is reported for the given time window. Requires --state-file`,
+	Example: `
+	$ check_logstash --state-file /tmp/example.json pipeline changes
+	[OK] - No pipeline changes
+	\_ [OK] pipeline example
+	    \_ [OK] Pipeline example running with hash 8a1d
+
+	$ check_logstash --state-file /tmp/example.json pipeline changes --window 30m
+	[WARNING] - Pipeline example configuration changed on 2021-01-01 02:07:14 +0000 UTC (hash 8a1d -> f3c2)
+	\_ [WARNING] pipeline example
+	    \_ [WARNING] Pipeline example configuration changed on 2021-01-01 02:07:14 +0000 UTC (hash 8a1d -> f3c2)`,
+	Run: func(_ *cobra.Command, _ []string) {
+		overall, err := evaluatePipelineChanges(&cliConfig, cliPipelineConfig)
+		if err != nil {
+			exitError(err)
 		}
 
-		check.ExitWithPerfdata(rc, perfList, output, summary.String())
+		exitOverall(overall)
 	},
 }
 
+// validatePipelineFlags validates the flags of the pipeline subcommands before the API is requested.
+func validatePipelineFlags(_ *cobra.Command, _ []string) {
+	err := cliPipelineConfig.validate()
+	if err != nil {
+		exitError(err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(pipelineCmd)
 
 	pipelineReloadCmd.Flags().StringVarP(&cliPipelineConfig.PipelineName, "pipeline", "P", "/",
 		"Pipeline Name")
+	pipelineReloadCmd.Flags().DurationVar(&cliPipelineConfig.FailureMaxAge, "failure-max-age", 0,
+		"Maximum age of a reload failure to be a critical result, older failures use the --failure-expired-state. Example: 1h")
+	pipelineReloadCmd.Flags().IntVar(&cliPipelineConfig.FailureExpiredState, "failure-expired-state", 1,
+		"Exit with specified code for reload failures older than --failure-max-age. Examples: 0 for OK, 1 for Warning")
+	pipelineReloadCmd.Flags().DurationVar(&cliPipelineConfig.SuccessMaxAge, "success-max-age", 0,
+		"Expected period in which a successful reload must have happened, e.g. after a deployment. Example: 24h")
+	pipelineReloadCmd.Flags().IntVar(&cliPipelineConfig.SuccessMissingState, "success-missing-state", 1,
+		"Exit with specified code if no successful reload happened within --success-max-age. Examples: 1 for Warning, 2 for Critical")
+	pipelineReloadCmd.Flags().StringVar(&cliPipelineConfig.FailureDeltaWarning, "failure-delta-warn", "",
+		"Warning threshold for reload failures since the last check run. Requires --state-file")
+	pipelineReloadCmd.Flags().StringVar(&cliPipelineConfig.FailureDeltaCrit, "failure-delta-crit", "",
+		"Critical threshold for reload failures since the last check run. Requires --state-file")
 
 	pipelineFlowCmd.Flags().StringVarP(&cliPipelineConfig.PipelineName, "pipeline", "P", "/",
 		"Pipeline Name")
@@ -400,8 +1180,67 @@ func init() {
 	_ = pipelineFlowCmd.MarkFlagRequired("warning")
 	_ = pipelineFlowCmd.MarkFlagRequired("critical")
 
+	pipelineLatencyCmd.Flags().StringVarP(&cliPipelineConfig.PipelineName, "pipeline", "P", "/",
+		"Pipeline Name")
+	pipelineLatencyCmd.Flags().StringVarP(&cliPipelineConfig.Warning, "warning", "w", "",
+		"Warning threshold for the average event latency in milliseconds")
+	pipelineLatencyCmd.Flags().StringVarP(&cliPipelineConfig.Critical, "critical", "c", "",
+		"Critical threshold for the average event latency in milliseconds")
+
+	pipelineLatencyCmd.Flags().BoolVar(&cliPipelineConfig.Interval, "interval", false,
+		"Calculate the value between the last and the current check run instead of over the lifetime. Requires --state-file")
+
+	_ = pipelineLatencyCmd.MarkFlagRequired("warning")
+	_ = pipelineLatencyCmd.MarkFlagRequired("critical")
+
+	pipelineBackpressureCmd.Flags().StringVarP(&cliPipelineConfig.PipelineName, "pipeline", "P", "/",
+		"Pipeline Name")
+	pipelineBackpressureCmd.Flags().StringVarP(&cliPipelineConfig.Warning, "warning", "w", "",
+		"Warning threshold for the queue push duration per event in milliseconds")
+	pipelineBackpressureCmd.Flags().StringVarP(&cliPipelineConfig.Critical, "critical", "c", "",
+		"Critical threshold for the queue push duration per event in milliseconds")
+
+	pipelineBackpressureCmd.Flags().BoolVar(&cliPipelineConfig.Interval, "interval", false,
+		"Calculate the value between the last and the current check run instead of over the lifetime. Requires --state-file")
+
+	_ = pipelineBackpressureCmd.MarkFlagRequired("warning")
+	_ = pipelineBackpressureCmd.MarkFlagRequired("critical")
+
+	pipelineChangesCmd.Flags().StringVarP(&cliPipelineConfig.PipelineName, "pipeline", "P", "/",
+		"Pipeline Name")
+	pipelineChangesCmd.Flags().DurationVar(&cliPipelineConfig.ChangeWindow, "window", time.Hour,
+		"Time window after a pipeline restart or configuration change in which to report it")
+	pipelineChangesCmd.Flags().IntVar(&cliPipelineConfig.ChangeState, "change-state", 1,
+		"Exit with specified code for changes within the --window. Examples: 1 for Warning, 2 for Critical")
+
+	for _, c := range []*cobra.Command{pipelineCmd, pipelineFlowCmd, pipelineLatencyCmd, pipelineBackpressureCmd} {
+		c.Flags().StringArrayVar(&cliPipelineConfig.Thresholds, "threshold", []string{},
+			"Override the thresholds for pipelines matching a pattern. Use pattern=warning:critical, or pattern=warning,critical if the warning is a range. Can be used multiple times")
+	}
+
+	for _, c := range []*cobra.Command{pipelineCmd, pipelineReloadCmd, pipelineFlowCmd, pipelineLatencyCmd, pipelineBackpressureCmd, pipelineChangesCmd} {
+		c.PreRun = validatePipelineFlags
+	}
+
 	pipelineCmd.AddCommand(pipelineReloadCmd)
 	pipelineCmd.AddCommand(pipelineFlowCmd)
+	pipelineCmd.AddCommand(pipelineLatencyCmd)
+	pipelineCmd.AddCommand(pipelineBackpressureCmd)
+	pipelineCmd.AddCommand(pipelineChangesCmd)
+
+	pfs := pipelineCmd.PersistentFlags()
+
+	pfs.StringArrayVar(&cliPipelineConfig.Include, "include", []string{},
+		"Only check pipelines matching this glob pattern. Can be used multiple times")
+	pfs.StringArrayVar(&cliPipelineConfig.Exclude, "exclude", []string{},
+		"Do not check pipelines matching this glob pattern. Can be used multiple times")
+	pfs.BoolVar(&cliPipelineConfig.Regex, "regex", false,
+		"Interpret --include, --exclude and the patterns of --threshold as regular expressions instead of glob patterns")
+
+	pfs.StringVar(&cliPipelineConfig.SortBy, "sort-by", "name",
+		"Sort the pipelines by name, state (worst first) or value (highest first)")
+
+	pfs.SortFlags = false
 
 	fs := pipelineCmd.Flags()
 
@@ -415,6 +1254,28 @@ func init() {
 	fs.StringVar(&cliPipelineConfig.Critical, "inflight-events-crit", "",
 		"Critical threshold for inflight events to be a critical result. Use min:max for a range.")
 
+	fs.StringVar(&cliPipelineConfig.OutRateWarning, "events-out-rate-warn", "",
+		"Warning threshold for events out per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.OutRateCritical, "events-out-rate-crit", "",
+		"Critical threshold for events out per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.InRateWarning, "events-in-rate-warn", "",
+		"Warning threshold for events in per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.InRateCritical, "events-in-rate-crit", "",
+		"Critical threshold for events in per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.FilteredRateWarning, "events-filtered-rate-warn", "",
+		"Warning threshold for filtered events per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.FilteredRateCrit, "events-filtered-rate-crit", "",
+		"Critical threshold for filtered events per second since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.PluginRateWarning, "plugin-events-out-rate-warn", "",
+		"Warning threshold for events out per second of each plugin since the last check run. Requires --state-file")
+	fs.StringVar(&cliPipelineConfig.PluginRateCritical, "plugin-events-out-rate-crit", "",
+		"Critical threshold for events out per second of each plugin since the last check run. Requires --state-file")
+
+	fs.IntVar(&cliPipelineConfig.StuckRuns, "stuck-runs", 0,
+		"Critical if events come in but none go out for this many consecutive check runs. Requires --state-file")
+	fs.DurationVar(&cliPipelineConfig.StuckDuration, "stuck-duration", 0,
+		"Critical if events come in but none go out for this duration. Requires --state-file. Example: 15m")
+
 	_ = pipelineCmd.MarkFlagRequired("inflight-events-warn")
 	_ = pipelineCmd.MarkFlagRequired("inflight-events-crit")
 
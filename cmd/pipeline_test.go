@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/NETWAYS/check_logstash/internal/state"
+	"github.com/NETWAYS/go-check"
 )
 
 func TestCalculateInflightEvents(t *testing.T) {
@@ -26,6 +32,322 @@ func TestCalculateInflightEvents(t *testing.T) {
 
 }
 
+func TestCalculateDurationPerEvent(t *testing.T) {
+	var actual float64
+
+	actual = calculateDurationPerEvent(100, 0)
+
+	if actual != 0 {
+		t.Error("\nActual: ", actual, "\nExpected: ", 0)
+	}
+
+	actual = calculateDurationPerEvent(500, 200)
+
+	if actual != 2.5 {
+		t.Error("\nActual: ", actual, "\nExpected: ", 2.5)
+	}
+}
+
+func TestReloadFailureState(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	actual := reloadFailureState(now.Add(-30*time.Minute), now, time.Hour, check.Warning)
+	if actual != check.Critical {
+		t.Error("\nActual: ", actual, "\nExpected: ", check.Critical)
+	}
+
+	actual = reloadFailureState(now.Add(-2*time.Hour), now, time.Hour, check.Warning)
+	if actual != check.Warning {
+		t.Error("\nActual: ", actual, "\nExpected: ", check.Warning)
+	}
+
+	actual = reloadFailureState(now.Add(-2*time.Hour), now, 0, check.OK)
+	if actual != check.Critical {
+		t.Error("\nActual: ", actual, "\nExpected: ", check.Critical)
+	}
+}
+
+func TestNewReloadResult(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		lastSuccess string
+		lastFailure string
+		state       check.Status
+		expected    string
+	}{
+		{"never-reloaded", "", "", check.OK, ""},
+		{"success-without-failure", "2021-01-01T11:00:00Z", "", check.OK, "Configuration successfully reloaded for pipeline main for on 2021-01-01 11:00:00 +0000 UTC"},
+		{"failure-without-success", "", "2021-01-01T11:30:00Z", check.Critical, "Configuration reload for pipeline main failed on 2021-01-01 11:30:00 +0000 UTC"},
+		{"old-failure-without-success", "", "2021-01-01T09:00:00Z", check.Warning, "Configuration reload for pipeline main failed on 2021-01-01 09:00:00 +0000 UTC, more than 1h0m0s ago"},
+		{"failure-after-success", "2021-01-01T11:00:00Z", "2021-01-01T11:30:00Z", check.Critical, "Configuration reload for pipeline main failed on 2021-01-01 11:30:00 +0000 UTC"},
+		{"success-after-failure", "2021-01-01T11:30:00Z", "2021-01-01T11:00:00Z", check.OK, "Configuration successfully reloaded for pipeline main for on 2021-01-01 11:30:00 +0000 UTC"},
+		{"invalid-timestamp", "not a timestamp", "", check.Unknown, "Configuration reload for pipeline main unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newReloadResult("main", test.lastSuccess, test.lastFailure, now, time.Hour, check.Warning)
+
+			if test.expected == "" {
+				if r != nil {
+					t.Error("\nActual: ", r.message, "\nExpected: ", nil)
+				}
+
+				return
+			}
+
+			if r == nil {
+				t.Fatal("\nActual: ", nil, "\nExpected: ", test.expected)
+			}
+
+			if r.GetStatus() != test.state || r.message != test.expected {
+				t.Error("\nActual: ", r.GetStatus(), r.message, "\nExpected: ", test.state, test.expected)
+			}
+		})
+	}
+}
+
+func TestReloadHappenedWithin(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if !reloadHappenedWithin("2021-01-01T11:30:00Z", now, time.Hour) {
+		t.Error("\nActual: ", false, "\nExpected: ", true)
+	}
+
+	if reloadHappenedWithin("2021-01-01T10:30:00Z", now, time.Hour) {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+
+	if reloadHappenedWithin("", now, time.Hour) {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+}
+
+func TestTrackStuckPipeline(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	prev := state.Sample{Timestamp: now, Counters: map[string]float64{"events.in": 100, "events.out": 50}}
+	cur := state.Sample{Timestamp: now.Add(5 * time.Minute), Counters: map[string]float64{"events.in": 150, "events.out": 50}}
+
+	trackStuckPipeline(prev, &cur)
+
+	if cur.StuckRuns != 1 || !cur.StuckSince.Equal(now) {
+		t.Error("\nActual: ", cur.StuckRuns, cur.StuckSince, "\nExpected: ", 1, now)
+	}
+
+	if !isStuckPipeline(cur, 1, 0) {
+		t.Error("\nActual: ", false, "\nExpected: ", true)
+	}
+
+	if isStuckPipeline(cur, 2, 10*time.Minute) {
+		t.Error("\nActual: ", true, "\nExpected: ", false)
+	}
+
+	if !isStuckPipeline(cur, 0, 5*time.Minute) {
+		t.Error("\nActual: ", false, "\nExpected: ", true)
+	}
+
+	next := state.Sample{Timestamp: now.Add(10 * time.Minute), Counters: map[string]float64{"events.in": 200, "events.out": 60}}
+
+	trackStuckPipeline(cur, &next)
+
+	if next.StuckRuns != 0 || !next.StuckSince.IsZero() {
+		t.Error("\nActual: ", next.StuckRuns, next.StuckSince, "\nExpected: ", 0, time.Time{})
+	}
+}
+
+func TestTrackPipelineChanges(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	last := state.Sample{Timestamp: now, Hash: "a", EphemeralID: "1", NodeEphemeralID: "n"}
+	cur := state.Sample{Timestamp: now.Add(time.Minute), Hash: "b", EphemeralID: "2", NodeEphemeralID: "n"}
+
+	trackPipelineChanges(last, &cur)
+
+	if cur.Change != "configuration changed" || cur.PreviousHash != "a" || !cur.ChangedAt.Equal(now.Add(time.Minute)) {
+		t.Error("\nActual: ", cur.Change, cur.PreviousHash, "\nExpected: ", "configuration changed", "a")
+	}
+
+	next := state.Sample{Timestamp: now.Add(2 * time.Minute), Hash: "b", EphemeralID: "3", NodeEphemeralID: "n"}
+
+	trackPipelineChanges(cur, &next)
+
+	if next.Change != "pipeline restarted" || next.PreviousHash != "" || !next.ChangedAt.Equal(now.Add(2*time.Minute)) {
+		t.Error("\nActual: ", next.Change, next.PreviousHash, "\nExpected: ", "pipeline restarted", "")
+	}
+
+	// The restart is not reported with the hash of the earlier configuration change
+	actual := pipelineChangeMessage("main", next)
+
+	expected := "Pipeline main pipeline restarted on 2021-01-01 12:02:00 +0000 UTC (hash b)"
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	actual = pipelineChangeMessage("main", cur)
+
+	expected = "Pipeline main configuration changed on 2021-01-01 12:01:00 +0000 UTC (hash a -> b)"
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	node := state.Sample{Timestamp: now.Add(4 * time.Minute), Hash: "c", EphemeralID: "4", NodeEphemeralID: "n"}
+
+	trackPipelineChanges(next, &node)
+
+	restarted := state.Sample{Timestamp: now.Add(5 * time.Minute), Hash: "c", EphemeralID: "5", NodeEphemeralID: "m"}
+
+	trackPipelineChanges(node, &restarted)
+
+	if restarted.Change != "Logstash restarted" || restarted.PreviousHash != "" {
+		t.Error("\nActual: ", restarted.Change, restarted.PreviousHash, "\nExpected: ", "Logstash restarted", "")
+	}
+
+	unchanged := state.Sample{Timestamp: now.Add(3 * time.Minute), Hash: "b", EphemeralID: "3", NodeEphemeralID: "n"}
+
+	trackPipelineChanges(next, &unchanged)
+
+	if unchanged.Change != "pipeline restarted" || !unchanged.ChangedAt.Equal(now.Add(2*time.Minute)) {
+		t.Error("\nActual: ", unchanged.Change, unchanged.ChangedAt, "\nExpected: ", "pipeline restarted", now.Add(2*time.Minute))
+	}
+}
+
+func TestNewRateResult(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	prev := state.Sample{Timestamp: now, Counters: map[string]float64{"events.in": 100, "plugins.b.events.out": 10}}
+	cur := state.Sample{Timestamp: now.Add(10 * time.Second), Counters: map[string]float64{"events.in": 150, "plugins.b.events.out": 5}}
+
+	crit := &check.Threshold{Lower: 10, Upper: check.PosInf}
+
+	rate, ok := newRateResult(prev, cur, "", "events.in", "main", "pipelines.main", nil, crit)
+	if !ok {
+		t.Fatal("\nActual: ", ok, "\nExpected: ", true)
+	}
+
+	if rate.GetStatus() != check.Critical || rate.message != "events_in_rate_main:5.00/s" {
+		t.Error("\nActual: ", rate.GetStatus(), rate.message, "\nExpected: ", check.Critical, "events_in_rate_main:5.00/s")
+	}
+
+	if rate.perfdata[0].Label != "pipelines.main.events.in_rate" {
+		t.Error("\nActual: ", rate.perfdata[0].Label, "\nExpected: ", "pipelines.main.events.in_rate")
+	}
+
+	// The counter of the plugin has been reset
+	_, ok = newRateResult(prev, cur, "plugins.b.", "events.out", "b", "pipelines.main.plugins.b", nil, nil)
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+}
+
+func TestPipelineThresholdOverrides(t *testing.T) {
+	thresholds, err := parsePipeThresholds(PipelineConfig{
+		Warning:    "10",
+		Critical:   "20",
+		Thresholds: []string{"beats=100:500", "syslog-*=@10:20,30"},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	warn, crit := thresholds.For("beats")
+	if warn.String() != "100" || crit.String() != "500" {
+		t.Error("\nActual: ", warn, crit, "\nExpected: ", "100 500")
+	}
+
+	warn, crit = thresholds.For("syslog-eu")
+	if warn.String() != "@10:20" || crit.String() != "30" {
+		t.Error("\nActual: ", warn, crit, "\nExpected: ", "@10:20 30")
+	}
+
+	warn, crit = thresholds.For("other")
+	if warn.String() != "10" || crit.String() != "20" {
+		t.Error("\nActual: ", warn, crit, "\nExpected: ", "10 20")
+	}
+
+	_, err = parsePipeThresholds(PipelineConfig{
+		Warning:    "10",
+		Critical:   "20",
+		Thresholds: []string{"beats"},
+	})
+	if err == nil {
+		t.Error("\nActual: ", err, "\nExpected: ", "error")
+	}
+
+	// Only the first colon separates the thresholds
+	ranges := map[string][2]string{
+		"main=10:20":       {"10", "20"},
+		"main=10:20:30":    {"10", "20:30"},
+		"main=10:@20:30":   {"10", "@20:30"},
+		"main=@5:10,20":    {"@5:10", "20"},
+		"main=@5:10,@1:20": {"@5:10", "@1:20"},
+		"main=10:~:5":      {"10", "~:5"},
+	}
+
+	for spec, expected := range ranges {
+		o, err := parsePipeThresholdOverride(spec, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if o.Warning.String() != expected[0] || o.Critical.String() != expected[1] {
+			t.Error("\nActual: ", o.Warning, o.Critical, "\nExpected: ", expected)
+		}
+	}
+
+	_, err = parsePipeThresholdOverride("main=@5:10", false)
+
+	expected := "could not parse threshold override main=@5:10, use pattern=warning,critical for a warning range"
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+}
+
+func TestSortPipelineResults(t *testing.T) {
+	newResults := func() []*pipelineResult {
+		results := []*pipelineResult{newPipelineResult("c"), newPipelineResult("a"), newPipelineResult("b")}
+
+		for i, s := range []check.Status{check.OK, check.Warning, check.Critical} {
+			results[i].value = []float64{5, 1, 10}[i]
+			results[i].partial.AddSubcheck(newStateResult("inflight_events", s, "inflight_events_%s", results[i].name))
+		}
+
+		return results
+	}
+
+	names := func(results []*pipelineResult) string {
+		var n []string
+		for _, r := range results {
+			n = append(n, r.name)
+		}
+
+		return strings.Join(n, ",")
+	}
+
+	results := newResults()
+	sortPipelineResults(results, "name")
+
+	if names(results) != "a,b,c" {
+		t.Error("\nActual: ", names(results), "\nExpected: ", "a,b,c")
+	}
+
+	results = newResults()
+	sortPipelineResults(results, "state")
+
+	if names(results) != "b,a,c" {
+		t.Error("\nActual: ", names(results), "\nExpected: ", "b,a,c")
+	}
+
+	results = newResults()
+	sortPipelineResults(results, "value")
+
+	if names(results) != "b,c,a" {
+		t.Error("\nActual: ", names(results), "\nExpected: ", "b,c,a")
+	}
+}
+
 func TestPipeline_ConnectionRefused(t *testing.T) {
 
 	cmd := exec.Command("go", "run", "../main.go", "pipeline", "--port", "9999", "--inflight-events-warn", "10", "--inflight-events-crit", "20")
@@ -73,7 +395,7 @@ func TestPipelineCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500"},
-			expected: "|pipelines.localhost-input.events.in=100c pipelines.localhost-input.events.out=50c inflight_events_localhost-input=50;200;500 pipelines.localhost-input.reloads.failures=0 pipelines.localhost-input.reloads.successes=0",
+			expected: "|pipelines.localhost-input.events.in=100c pipelines.localhost-input.events.out=50c pipelines.localhost-input.reloads.failures=0 pipelines.localhost-input.reloads.successes=0 inflight_events_localhost-input=50;200;500",
 		},
 		{
 			name: "pipeline-missing",
@@ -91,7 +413,7 @@ func TestPipelineCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "25", "--inflight-events-crit", "60"},
-			expected: "[WARNING] - Inflight events",
+			expected: "[WARNING] - inflight_events_localhost-input:50",
 		},
 		{
 			name: "pipeline-inflight-crit",
@@ -100,7 +422,7 @@ func TestPipelineCmd_Logstash7(t *testing.T) {
 				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "25", "--inflight-events-crit", "49"},
-			expected: "[CRITICAL] - Inflight events",
+			expected: "[CRITICAL] - inflight_events_localhost-input:50",
 		},
 	}
 
@@ -141,7 +463,7 @@ func TestPipelineCmd_Logstash8(t *testing.T) {
 				w.Write([]byte(`{"host":"localhost","version":"8.6","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":"","last_error":null,"last_failure_timestamp":"2020-10-11T01:10:10.11Z","failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "reload"},
-			expected: "[UNKNOWN] - Configuration reload status unknown",
+			expected: "[CRITICAL] - Configuration reload for pipeline localhost-input failed on 2020-10-11 01:10:10.11 +0000 UTC",
 		},
 		{
 			name: "pipeline-reload-not-timestamp",
@@ -161,6 +483,24 @@ func TestPipelineCmd_Logstash8(t *testing.T) {
 			args:     []string{"run", "../main.go", "pipeline", "reload"},
 			expected: "[CRITICAL] Configuration reload for pipeline localhost-input",
 		},
+		{
+			name: "pipeline-reload-failed-expired",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.6","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":"2020-10-11T01:10:10.11Z","last_error":null,"last_failure_timestamp":"2021-10-11T01:10:10.11Z","failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "reload", "--failure-max-age", "1h"},
+			expected: "[WARNING] Configuration reload for pipeline localhost-input failed on 2021-10-11 01:10:10.11 +0000 UTC, more than 1h0m0s ago",
+		},
+		{
+			name: "pipeline-reload-success-missing",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.6","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":"2020-10-11T01:10:10.11Z","last_error":null,"last_failure_timestamp":"2021-10-11T01:10:10.11Z","failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "reload", "--success-max-age", "24h", "--success-missing-state", "2"},
+			expected: "[CRITICAL] No configuration reload for pipeline localhost-input within 24h0m0s",
+		},
 		{
 			name: "pipeline-reload-ok",
 			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -186,7 +526,16 @@ func TestPipelineCmd_Logstash8(t *testing.T) {
 				w.Write([]byte(`{"host":"foobar","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"ansible-input":{"flow":{"queue_backpressure":{"current":12.34,"last_1_minute":0,"lifetime":2.503e-05},"output_throughput":{"current":0,"last_1_minute":0.344,"lifetime":0.7051},"input_throughput":{"current":10,"last_1_minute":0.5734,"lifetime":1.089},"worker_concurrency":{"current":0.0001815,"last_1_minute":0.0009501,"lifetime":0.003384},"filter_throughput":{"current":0,"last_1_minute":0.5734,"lifetime":1.089}},"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "15", "--critical", "20"},
-			expected: "[OK] queue_backpressure_ansible-input:12.34;|pipelines.queue_backpressure_ansible-input=12.34;15;20 pipelines.ansible-input.output_throughput=0 pipelines.ansible-input.input_throughput=10 pipelines.ansible-input.filter_throughput=0",
+			expected: "\\_ [OK] queue_backpressure_ansible-input:12.34\n|pipelines.ansible-input.output_throughput=0 pipelines.ansible-input.input_throughput=10 pipelines.ansible-input.filter_throughput=0 pipelines.queue_backpressure_ansible-input=12.34;15;20",
+		},
+		{
+			name: "pipeline-flow-json",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"foobar","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"ansible-input":{"flow":{"queue_backpressure":{"current":12.34,"last_1_minute":0,"lifetime":2.503e-05},"output_throughput":{"current":0,"last_1_minute":0.344,"lifetime":0.7051},"input_throughput":{"current":10,"last_1_minute":0.5734,"lifetime":1.089},"worker_concurrency":{"current":0.0001815,"last_1_minute":0.0009501,"lifetime":0.003384},"filter_throughput":{"current":0,"last_1_minute":0.5734,"lifetime":1.089}},"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "15", "--critical", "20", "--output", "json"},
+			expected: "\"name\": \"queue_backpressure\",\n          \"state\": \"OK\",\n          \"value\": 12.34,\n          \"warning\": \"15\",\n          \"critical\": \"20\",\n          \"message\": \"queue_backpressure_ansible-input:12.34\"",
 		},
 		{
 			name: "pipeline-flow-critical",
@@ -195,7 +544,79 @@ func TestPipelineCmd_Logstash8(t *testing.T) {
 				w.Write([]byte(`{"host":"foobar","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"ansible-input":{"flow":{"queue_backpressure":{"current":10,"last_1_minute":0,"lifetime":2.503e-05},"output_throughput":{"current":0,"last_1_minute":0.344,"lifetime":0.7051},"input_throughput":{"current":10,"last_1_minute":0.5734,"lifetime":1.089},"worker_concurrency":{"current":0.0001815,"last_1_minute":0.0009501,"lifetime":0.003384},"filter_throughput":{"current":0,"last_1_minute":0.5734,"lifetime":1.089}},"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
 			})),
 			args:     []string{"run", "../main.go", "pipeline", "flow", "--warning", "1", "--critical", "2"},
-			expected: "[CRITICAL] - Flow metrics not alright",
+			expected: "[CRITICAL] - queue_backpressure_ansible-input:10.00",
+		},
+		{
+			name: "pipeline-latency-ok",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":0,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "latency", "--warning", "50", "--critical", "100"},
+			expected: "\\_ [OK] event_latency_localhost-input:10.00ms\n|pipelines.localhost-input.events.duration_in_millis=500c pipelines.event_latency_localhost-input=10ms;50;100",
+		},
+		{
+			name: "pipeline-latency-warning",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":0,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "latency", "--warning", "5", "--critical", "100"},
+			expected: "[WARNING] - event_latency_localhost-input:10.00ms",
+		},
+		{
+			name: "pipeline-latency-plugins",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.6","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":100}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":100,"out":50,"in":50}}]},"reloads":{"successes":0,"failures":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "latency", "--warning", "50", "--critical", "100"},
+			expected: "\\_ [OK] pipeline localhost-input\n    \\_ [OK] event_latency_localhost-input:10.00ms\n    \\_ [OK] plugin f (redis)\n        \\_ [OK] event_latency_f:2.00ms\n",
+		},
+		{
+			name: "pipeline-latency-plugins-without-duration",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"8.6","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":100}}],"filters":[{"id":"d","name":"drop","events":{"duration_in_millis":10,"out":0,"in":50}}],"outputs":[]},"reloads":{"successes":0,"failures":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "latency", "--warning", "50", "--critical", "100"},
+			expected: "\\_ [OK] pipeline localhost-input\n    \\_ [OK] event_latency_localhost-input:10.00ms\n|",
+		},
+		{
+			name: "pipeline-backpressure-ok",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":300,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "backpressure", "--warning", "5", "--critical", "100"},
+			expected: "\\_ [OK] queue_push_duration_localhost-input:3.00ms\n|pipelines.localhost-input.events.queue_push_duration_in_millis=300c pipelines.queue_push_duration_localhost-input=3ms;5;100",
+		},
+		{
+			name: "pipeline-backpressure-critical",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"host":"localhost","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"localhost-input":{"events":{"filtered":50,"duration_in_millis":500,"queue_push_duration_in_millis":300,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "backpressure", "--warning", "1", "--critical", "2"},
+			expected: "[CRITICAL] - queue_push_duration_localhost-input:3.00ms",
+		},
+		{
+			name: "pipeline-latency-interval-without-state-file",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "latency", "--warning", "50", "--critical", "100", "--interval"},
+			expected: "[UNKNOWN] - --interval requires a --state-file",
+		},
+		{
+			name: "pipeline-rate-without-state-file",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{}`))
+			})),
+			args:     []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "5", "--inflight-events-crit", "10", "--events-in-rate-crit", "1:"},
+			expected: "[UNKNOWN] - --events-in-rate-crit requires a --state-file",
 		},
 	}
 
@@ -217,3 +638,307 @@ func TestPipelineCmd_Logstash8(t *testing.T) {
 		})
 	}
 }
+
+func TestPipelineCmd_StateFile(t *testing.T) {
+	failures := 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":%d},"hash":"f","ephemeral_id":"f"}}}`, failures)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"run", "../main.go", "pipeline", "reload", "--state-file", stateFile, "--failure-delta-warn", "1", "--port", u.Port()}
+
+	// The first run has no previous sample
+	out, _ := exec.Command("go", args...).CombinedOutput()
+
+	actual := string(out)
+	expected := "since last check"
+
+	if strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nNot Expected: ", expected)
+	}
+
+	failures = 3
+
+	out, _ = exec.Command("go", args...).CombinedOutput()
+
+	actual = string(out)
+	expected = "[WARNING] 2 configuration reload failures for pipeline localhost-input since last check"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	// Without a state file there are no failures since the last check
+	out, _ = exec.Command("go", "run", "../main.go", "pipeline", "reload", "--failure-delta-crit", "1", "--port", u.Port()).CombinedOutput()
+
+	actual = string(out)
+	expected = "[UNKNOWN] - --failure-delta-crit requires a --state-file"
+
+	if !strings.HasPrefix(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_RateThresholds(t *testing.T) {
+	in := 100
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","pipelines":{"main":{"events":{"filtered":%d,"out":%d,"in":%d},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"out":%d}}],"filters":[],"outputs":[]},"reloads":{"successes":0,"failures":0},"hash":"f","ephemeral_id":"f"}}}`, in, in, in, in)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "10", "--inflight-events-crit", "20",
+		"--state-file", stateFile, "--events-in-rate-warn", "1000000:", "--events-filtered-rate-crit", "1000000:",
+		"--plugin-events-out-rate-crit", "1000000:", "--port", u.Port()}
+
+	// The first run has no previous sample
+	out, _ := exec.Command("go", args...).CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Inflight events alright"
+
+	if !strings.HasPrefix(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	in = 200
+
+	out, _ = exec.Command("go", args...).CombinedOutput()
+
+	actual = string(out)
+
+	for _, expected := range []string{"[WARNING] events_in_rate_main:", "[CRITICAL] events_filtered_rate_main:",
+		"[CRITICAL] plugin b (beats)\n        \\_ [CRITICAL] events_out_rate_b:", "pipelines.main.plugins.b.events.out_rate="} {
+		if !strings.Contains(actual, expected) {
+			t.Error("\nActual: ", actual, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestPipelineCmd_SharedStateFile(t *testing.T) {
+	failures := 1
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":%d},"hash":"f","ephemeral_id":"f"}}}`, failures)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	reload := []string{"run", "../main.go", "pipeline", "reload", "--state-file", stateFile, "--failure-delta-warn", "1", "--port", u.Port()}
+	pipeline := []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500",
+		"--state-file", stateFile, "--port", u.Port()}
+
+	_, _ = exec.Command("go", reload...).CombinedOutput()
+
+	failures = 3
+
+	// The pipeline command must not replace the sample of pipeline reload
+	_, _ = exec.Command("go", pipeline...).CombinedOutput()
+
+	out, _ := exec.Command("go", reload...).CombinedOutput()
+
+	actual := string(out)
+	expected := "[WARNING] 2 configuration reload failures for pipeline localhost-input since last check"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_Stuck(t *testing.T) {
+	in := 100
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":%d},"hash":"f","ephemeral_id":"f"}}}`, in)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500",
+		"--state-file", stateFile, "--stuck-runs", "1", "--port", u.Port()}
+
+	// The first run has no previous sample
+	out, _ := exec.Command("go", args...).CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Inflight events alright"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	in = 150
+
+	out, _ = exec.Command("go", args...).CombinedOutput()
+
+	actual = string(out)
+	expected = "(1 runs without events out)"
+
+	if !strings.Contains(actual, "[CRITICAL] Pipeline localhost-input stuck for") || !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	// Without a state file the pipeline could never be stuck
+	out, _ = exec.Command("go", "run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500",
+		"--stuck-duration", "15m", "--port", u.Port()).CombinedOutput()
+
+	actual = string(out)
+	expected = "[UNKNOWN] - --stuck-duration requires a --state-file"
+
+	if !strings.HasPrefix(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_StuckWithOtherSubcommand(t *testing.T) {
+	in := 100
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","pipelines":{"localhost-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":%d},"reloads":{"successes":0,"failures":0},"hash":"f","ephemeral_id":"f"}}}`, in)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	pipeline := []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500",
+		"--state-file", stateFile, "--stuck-runs", "2", "--port", u.Port()}
+	reload := []string{"run", "../main.go", "pipeline", "reload", "--state-file", stateFile, "--port", u.Port()}
+
+	_, _ = exec.Command("go", pipeline...).CombinedOutput()
+
+	in = 150
+
+	_, _ = exec.Command("go", pipeline...).CombinedOutput()
+
+	// A run of another subcommand between two stuck runs must not reset the tracking
+	_, _ = exec.Command("go", reload...).CombinedOutput()
+
+	in = 200
+
+	out, _ := exec.Command("go", pipeline...).CombinedOutput()
+
+	actual := string(out)
+	expected := "(2 runs without events out)"
+
+	if !strings.Contains(actual, "[CRITICAL] Pipeline localhost-input stuck for") || !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_Changes(t *testing.T) {
+	hash := "f"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","ephemeral_id":"5","pipelines":{"localhost-input":{"events":{"out":50,"in":100},"hash":"%s","ephemeral_id":"%s"}}}`, hash, hash)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"run", "../main.go", "pipeline", "changes", "--state-file", stateFile, "--port", u.Port()}
+
+	out, _ := exec.Command("go", args...).CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] Pipeline localhost-input running with hash f"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	hash = "g"
+
+	out, _ = exec.Command("go", args...).CombinedOutput()
+
+	actual = string(out)
+	expected = "(hash f -> g)"
+
+	if !strings.Contains(actual, "[WARNING] Pipeline localhost-input configuration changed on") || !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	out, _ = exec.Command("go", "run", "../main.go", "pipeline", "changes", "--port", u.Port()).CombinedOutput()
+
+	actual = string(out)
+	expected = "[UNKNOWN] - checking pipeline changes requires a --state-file"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_ChangesWithOtherSubcommand(t *testing.T) {
+	hash := "f"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","ephemeral_id":"5","pipelines":{"localhost-input":{"events":{"out":50,"in":100},"hash":"%s","ephemeral_id":"%s"}}}`, hash, hash)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	changes := []string{"run", "../main.go", "pipeline", "changes", "--state-file", stateFile, "--port", u.Port()}
+	pipeline := []string{"run", "../main.go", "pipeline", "--inflight-events-warn", "200", "--inflight-events-crit", "500",
+		"--state-file", stateFile, "--port", u.Port()}
+
+	_, _ = exec.Command("go", changes...).CombinedOutput()
+
+	hash = "g"
+
+	_, _ = exec.Command("go", changes...).CombinedOutput()
+
+	// A run of another subcommand must not clear the change within the --window
+	_, _ = exec.Command("go", pipeline...).CombinedOutput()
+
+	out, _ := exec.Command("go", changes...).CombinedOutput()
+
+	actual := string(out)
+	expected := "(hash f -> g)"
+
+	if !strings.Contains(actual, "[WARNING] Pipeline localhost-input configuration changed on") || !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestPipelineCmd_ChangesSortByValue(t *testing.T) {
+	hash := "f"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"host":"localhost","version":"7.17.8","ephemeral_id":"5","pipelines":{"a":{"hash":"a","ephemeral_id":"a"},"b":{"hash":"%s","ephemeral_id":"b"}}}`, hash)
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+	changes := []string{"run", "../main.go", "pipeline", "changes", "--state-file", stateFile, "--port", u.Port(), "--sort-by", "value"}
+
+	_, _ = exec.Command("go", changes...).CombinedOutput()
+
+	hash = "g"
+
+	out, _ := exec.Command("go", changes...).CombinedOutput()
+
+	// The most recently changed pipeline is listed first
+	actual := string(out)
+	expected := "\\_ [WARNING] pipeline b\n"
+
+	if !strings.Contains(actual, "(hash f -> g)\n\\_ [OK] pipeline a\n") || !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
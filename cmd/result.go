@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/result"
+)
+
+// checkOverall is the overall result of a command. It wraps the result tree
+// of go-check and keeps the details of the subchecks for the other output formats.
+type checkOverall struct {
+	overall   result.Overall
+	subchecks []*checkResult
+}
+
+// AddSubcheck adds a top-level subcheck to the overall result.
+func (o *checkOverall) AddSubcheck(c *checkResult) {
+	o.subchecks = append(o.subchecks, c)
+	o.overall.AddSubcheck(c.partial)
+}
+
+// SetOKSummary sets the summary that is used if the overall result is OK.
+func (o *checkOverall) SetOKSummary(summary string) {
+	o.overall.SetOKSummary(summary)
+}
+
+// GetStatus returns the worst state of all subchecks.
+func (o *checkOverall) GetStatus() check.Status {
+	return o.overall.GetStatus()
+}
+
+// GetOutput returns the summary, the long output and the perfdata of the result tree.
+func (o *checkOverall) GetOutput() string {
+	return o.overall.GetOutput()
+}
+
+// Perfdata returns the perfdata of all subchecks in the order of the output.
+func (o *checkOverall) Perfdata() check.PerfdataList {
+	var perfdata check.PerfdataList
+
+	var collect func(c *checkResult)
+	collect = func(c *checkResult) {
+		perfdata = append(perfdata, c.perfdata...)
+
+		for _, sub := range c.subchecks {
+			collect(sub)
+		}
+	}
+
+	for _, c := range o.subchecks {
+		collect(c)
+	}
+
+	return perfdata
+}
+
+// checkResult is a subcheck in the result tree. Besides the partial result
+// it keeps the name, value, thresholds and perfdata of the subcheck.
+type checkResult struct {
+	name      string
+	message   string
+	value     *float64
+	warn      *check.Threshold
+	crit      *check.Threshold
+	perfdata  check.PerfdataList
+	subchecks []*checkResult
+	partial   *result.PartialResult
+	// labels identify the pipeline or plugin of the subcheck and its nested subchecks
+	labels map[string]string
+}
+
+// newCheckResult creates a subcheck, its state is the worst state of its own subchecks.
+func newCheckResult(name, format string, a ...any) *checkResult {
+	c := &checkResult{
+		name:    name,
+		message: fmt.Sprintf(format, a...),
+		partial: result.NewPartialResult(),
+	}
+
+	c.partial.SetOutput(c.message)
+
+	return c
+}
+
+// newStateResult creates a subcheck with an explicit state.
+func newStateResult(name string, state check.Status, format string, a ...any) *checkResult {
+	c := newCheckResult(name, format, a...)
+	c.partial.SetState(state)
+
+	return c
+}
+
+// newThresholdResult creates a subcheck for a value,
+// the state is determined by the (optional) thresholds.
+func newThresholdResult(name string, value float64, warn, crit *check.Threshold, format string, a ...any) *checkResult {
+	state := check.OK
+
+	if violatesOptionalThreshold(crit, value) {
+		state = check.Critical
+	} else if violatesOptionalThreshold(warn, value) {
+		state = check.Warning
+	}
+
+	c := newStateResult(name, state, format, a...)
+	c.value = &value
+	c.warn = warn
+	c.crit = crit
+
+	return c
+}
+
+// AddSubcheck adds a nested subcheck.
+func (c *checkResult) AddSubcheck(sub *checkResult) {
+	c.subchecks = append(c.subchecks, sub)
+	c.partial.AddSubcheck(sub.partial)
+}
+
+// AddPerfdata adds perfdata to the subcheck.
+func (c *checkResult) AddPerfdata(p *check.Perfdata) {
+	c.perfdata.Add(p)
+	c.partial.AddPerfdata(p)
+}
+
+// GetStatus returns the state of the subcheck.
+func (c *checkResult) GetStatus() check.Status {
+	return c.partial.GetStatus()
+}
+
+// pipelineResult is the partial result of a single pipeline,
+// collected before rendering so the pipelines can be sorted.
+type pipelineResult struct {
+	name    string
+	value   float64
+	partial *checkResult
+}
+
+// newPipelineResult creates the partial result of a pipeline,
+// the metrics and plugins of the pipeline are added as subchecks.
+func newPipelineResult(name string) *pipelineResult {
+	c := newCheckResult(name, "pipeline %s", name)
+	c.labels = map[string]string{"pipeline": name}
+
+	return &pipelineResult{
+		name:    name,
+		partial: c,
+	}
+}
+
+// newPluginResult creates the partial result of a plugin within a pipeline.
+func newPluginResult(plugin logstash.Plugin) *checkResult {
+	c := newCheckResult(plugin.ID, "plugin %s (%s)", plugin.ID, plugin.Name)
+	c.labels = map[string]string{"plugin": plugin.ID}
+
+	return c
+}
+
+// sortPipelineResults sorts the results by name, by state with the worst
+// state first, or by value with the highest value first.
+func sortPipelineResults(results []*pipelineResult, sortBy string) {
+	slices.SortStableFunc(results, func(a, b *pipelineResult) int {
+		switch sortBy {
+		case "state":
+			if c := check.Compare(a.partial.GetStatus(), b.partial.GetStatus()); c != 0 {
+				return c
+			}
+		case "value":
+			if c := cmp.Compare(b.value, a.value); c != 0 {
+				return c
+			}
+		}
+
+		return strings.Compare(a.name, b.name)
+	})
+}
+
+// addPipelineResults sorts the results and adds them to the overall result.
+// Without any results the result is Unknown with the given summary.
+func addPipelineResults(o *checkOverall, results []*pipelineResult, sortBy, unknownSummary string) {
+	if len(results) == 0 {
+		o.AddSubcheck(newStateResult("pipelines", check.Unknown, "%s", unknownSummary))
+		return
+	}
+
+	sortPipelineResults(results, sortBy)
+
+	for _, r := range results {
+		o.AddSubcheck(r.partial)
+	}
+}
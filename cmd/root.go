@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/NETWAYS/go-check"
 	"github.com/spf13/cobra"
@@ -9,11 +12,36 @@ import (
 
 var Timeout = 30
 
+// commandPath is the path of the executed subcommand, e.g. "pipeline flow"
+var commandPath string
+
 var rootCmd = &cobra.Command{
 	Use:   "check_logstash",
 	Short: "An Icinga check plugin to check Logstash",
-	PersistentPreRun: func(_ *cobra.Command, _ []string) {
-		go check.HandleTimeout(Timeout)
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		commandPath = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+		// The environment and then the profile set the flags that are not given on the command line,
+		// the errors are rendered in the output format given so far
+		err := loadEnv(cmd)
+		if err != nil {
+			exitError(err)
+		}
+
+		err = loadProfile(cmd, cliConfig.ConfigFile, cliConfig.Profile)
+		if err != nil {
+			exitError(err)
+		}
+
+		// Long-running commands handle the timeout per request
+		if cmd.Annotations["long-running"] == "" {
+			go check.HandleTimeout(Timeout)
+		}
+
+		// An invalid output format falls back to the text output
+		if !slices.Contains(outputFormats, cliConfig.Output) {
+			exitError(fmt.Errorf("invalid output format %s, use %s", cliConfig.Output, strings.Join(outputFormats, ", ")))
+		}
 	},
 	Run: Usage,
 }
@@ -24,6 +52,8 @@ func Execute(version string) {
 	rootCmd.Version = version
 	rootCmd.VersionTemplate()
 
+	addEnvUsage(rootCmd)
+
 	err := rootCmd.Execute()
 	if err != nil {
 		check.ExitError(err)
@@ -40,24 +70,84 @@ func init() {
 	})
 
 	pfs := rootCmd.PersistentFlags()
+	pfs.StringVarP(&cliConfig.ConfigFile, "config", "", "",
+		"Read the flags from a profile of this configuration file, flags on the command line take precedence")
+	pfs.StringVarP(&cliConfig.Profile, "profile", "", "",
+		"Name of the profile in the configuration file, uses the profile default if not given")
 	pfs.StringVarP(&cliConfig.Hostname, "hostname", "H", "localhost",
-		"Hostname of the Logstash server (CHECK_LOGSTASH_HOSTNAME)")
+		"Hostname of the Logstash server")
 	pfs.IntVarP(&cliConfig.Port, "port", "p", 9600,
 		"Port of the Logstash server")
+	pfs.StringVarP(&cliConfig.URL, "url", "", "",
+		"Base URL of the Logstash API with scheme, port and path prefix, takes precedence over --hostname, --port and --secure. Example: https://proxy/logstash-eu/")
+	pfs.StringVarP(&cliConfig.UnixSocket, "unix-socket", "", "",
+		"Connect to the Logstash API via this Unix domain socket, e.g. of a local socket proxy. Example: /run/logstash-api.sock")
+	pfs.StringArrayVarP(&cliConfig.Resolve, "resolve", "", []string{},
+		"Connect to this address instead of resolving the host, like curl. Use host:port:address. Can be used multiple times")
 	pfs.BoolVarP(&cliConfig.Secure, "secure", "s", false,
 		"Use a HTTPS connection")
 	pfs.BoolVarP(&cliConfig.Insecure, "insecure", "i", false,
 		"Skip the verification of the server's TLS certificate")
 	pfs.StringVarP(&cliConfig.Bearer, "bearer", "b", "",
-		"Specify the Bearer Token for server authentication (CHECK_LOGSTASH_BEARER)")
+		"Specify the Bearer Token for server authentication")
+	pfs.StringVarP(&cliConfig.BearerFile, "bearer-file", "", "",
+		"Read the Bearer Token for server authentication from this file")
+	pfs.StringVarP(&cliConfig.APIKey, "api-key", "", "",
+		"Specify the API key for server authentication, sent as Authorization: ApiKey <key>")
+	pfs.StringVarP(&cliConfig.APIKeyFile, "api-key-file", "", "",
+		"Read the API key for server authentication from this file")
 	pfs.StringVarP(&cliConfig.BasicAuth, "user", "u", "",
-		"Specify the user name and password for server authentication <user:password> (CHECK_LOGSTASH_BASICAUTH)")
+		"Specify the user name and password for server authentication <user:password>, or only the user name with --password-file or --credentials-command")
+	pfs.StringVarP(&cliConfig.PasswordFile, "password-file", "", "",
+		"Read the password of --user from this file")
+	pfs.StringVarP(&cliConfig.CredentialsCommand, "credentials-command", "", "",
+		"Run this command and use its output as password of --user, or without --user as Bearer Token")
+	pfs.BoolVarP(&cliConfig.Netrc, "netrc", "", false,
+		"Read the user name and password from the netrc file of $NETRC or ~/.netrc if no other credentials are given")
+	pfs.StringVarP(&cliConfig.NetrcFile, "netrc-file", "", "",
+		"Read the user name and password from this netrc file if no other credentials are given")
+	pfs.StringArrayVarP(&cliConfig.Headers, "header", "", []string{},
+		"Add this HTTP header to the requests to the Logstash API. Use 'Name: value'. Can be used multiple times")
 	pfs.StringVarP(&cliConfig.CAFile, "ca-file", "", "",
-		"Specify the CA File for TLS authentication (CHECK_LOGSTASH_CA_FILE)")
+		"Specify the CA File for TLS authentication")
 	pfs.StringVarP(&cliConfig.CertFile, "cert-file", "", "",
-		"Specify the Certificate File for TLS authentication (CHECK_LOGSTASH_CERT_FILE)")
+		"Specify the Certificate File for TLS authentication")
 	pfs.StringVarP(&cliConfig.KeyFile, "key-file", "", "",
-		"Specify the Key File for TLS authentication (CHECK_LOGSTASH_KEY_FILE)")
+		"Specify the Key File for TLS authentication")
+	pfs.StringVarP(&cliConfig.PKCS12File, "pkcs12-file", "", "",
+		"Specify the PKCS#12 File with the certificate and key for TLS authentication, instead of --cert-file and --key-file")
+	pfs.StringVarP(&cliConfig.PKCS12Password, "pkcs12-password", "", "",
+		"Specify the password of the PKCS#12 File")
+	pfs.StringVarP(&cliConfig.PKCS12PasswordFile, "pkcs12-password-file", "", "",
+		"Read the password of the PKCS#12 File from this file")
+	pfs.StringVarP(&cliConfig.TLSServerName, "tls-server-name", "", "",
+		"Verify the TLS certificate of the Logstash server for this name instead of the host, also sent as SNI")
+	pfs.StringVarP(&cliConfig.TLSMinVersion, "tls-min-version", "", "",
+		"Minimum TLS version of the connection, use 1.0, 1.1, 1.2 or 1.3")
+	pfs.IntVarP(&cliConfig.TLSExpiryWarnDays, "tls-expiry-warn-days", "", 0,
+		"Warn if the TLS certificate of the Logstash server expires within this number of days")
+	pfs.StringVarP(&cliConfig.StateFile, "state-file", "", "",
+		"Persist the counters between check runs in this file to calculate rates and deltas")
+	pfs.StringVarP(&cliConfig.ExpectNodeName, "expect-node-name", "", "",
+		"Verify the name of the Logstash node")
+	pfs.StringVarP(&cliConfig.ExpectNodeID, "expect-node-id", "", "",
+		"Verify the ID of the Logstash node")
+	pfs.StringVarP(&cliConfig.ExpectHost, "expect-host", "", "",
+		"Verify the host of the Logstash node")
+	pfs.IntVarP(&cliConfig.IdentityMismatchState, "identity-mismatch-state", "", 2,
+		"Exit with specified code if the Logstash node does not match the expected identity. Examples: 1 for Warning, 2 for Critical, 3 for Unknown")
+	pfs.BoolVarP(&cliConfig.OnlyProblems, "only-problems", "", false,
+		"Only show the non-OK sub-results in the long output and the number of sub-results per state")
+	pfs.StringVarP(&cliConfig.Output, "output", "", "icinga",
+		"Output format of the check result, use icinga, json or openmetrics")
+	pfs.StringVarP(&cliConfig.Icinga2URL, "submit-icinga2", "", "",
+		"Submit the check result to this Icinga 2 API as passive check result, in addition to printing it. Example: https://icinga:5665")
+	pfs.StringVarP(&cliConfig.Icinga2User, "icinga2-user", "", "",
+		"Specify the user name and password of the Icinga 2 API user <user:password>")
+	pfs.StringVarP(&cliConfig.Icinga2Host, "icinga2-host", "", "",
+		"Icinga 2 host of the submitted check result, uses --hostname if not given")
+	pfs.StringVarP(&cliConfig.Icinga2Service, "icinga2-service", "", "",
+		"Icinga 2 service of the submitted check result, the result is submitted for the host if not given")
 	pfs.IntVarP(&Timeout, "timeout", "t", Timeout,
 		"Timeout in seconds for the CheckPlugin")
 
@@ -66,8 +156,6 @@ func init() {
 
 	help := rootCmd.HelpTemplate()
 	rootCmd.SetHelpTemplate(help + Copyright)
-
-	check.LoadFromEnv(&cliConfig)
 }
 
 func Usage(cmd *cobra.Command, _ []string) {
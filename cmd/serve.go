@@ -0,0 +1,464 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// ServeConfig for the CLI parameters.
+type ServeConfig struct {
+	Listen    string
+	Interval  time.Duration
+	Instances []string
+	Checks    []string
+}
+
+var cliServeConfig ServeConfig
+
+// evaluateFunc evaluates a check against a Logstash instance.
+type evaluateFunc func(cfg *Config) (*checkOverall, error)
+
+// serveEvaluators bind the evaluation of a subcommand to a snapshot of its current flags,
+// returns an error if the flags are invalid for the global config of the server.
+var serveEvaluators = map[*cobra.Command]func(base *Config) (evaluateFunc, error){
+	healthCmd: func(_ *Config) (evaluateFunc, error) {
+		hc := cliHealthConfig
+
+		return func(cfg *Config) (*checkOverall, error) {
+			return evaluateHealth(cfg, hc)
+		}, nil
+	},
+	pipelineCmd:             pipelineEvaluator(evaluatePipeline),
+	pipelineReloadCmd:       pipelineEvaluator(evaluatePipelineReload),
+	pipelineFlowCmd:         pipelineEvaluator(evaluatePipelineFlow),
+	pipelineLatencyCmd:      pipelineEvaluator(evaluatePipelineLatency),
+	pipelineBackpressureCmd: pipelineEvaluator(evaluatePipelineBackpressure),
+	pipelineChangesCmd: func(base *Config) (evaluateFunc, error) {
+		if base.StateFile == "" {
+			return nil, errChangesStateFile
+		}
+
+		return pipelineEvaluator(evaluatePipelineChanges)(base)
+	},
+}
+
+// pipelineEvaluator binds a pipeline evaluation to a snapshot of the pipeline flags.
+func pipelineEvaluator(evaluate func(*Config, PipelineConfig) (*checkOverall, error)) func(*Config) (evaluateFunc, error) {
+	return func(base *Config) (evaluateFunc, error) {
+		pc := cliPipelineConfig
+		pc.Thresholds = slices.Clone(pc.Thresholds)
+		pc.Include = slices.Clone(pc.Include)
+		pc.Exclude = slices.Clone(pc.Exclude)
+
+		err := pc.validate()
+		if err != nil {
+			return nil, err
+		}
+
+		// Refuse checks that would never alert, instead of failing on every poll
+		err = requireStateFile(base, pc.stateFileFlags()...)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(cfg *Config) (*checkOverall, error) {
+			return evaluate(cfg, pc)
+		}, nil
+	}
+}
+
+// serveInstance is a Logstash instance polled by the server.
+type serveInstance struct {
+	name string
+	cfg  Config
+}
+
+// serveCheck is a subcommand evaluated by the server.
+type serveCheck struct {
+	name     string
+	evaluate evaluateFunc
+}
+
+// serveResult is the latest result of a check against an instance.
+type serveResult struct {
+	overall *checkOverall
+	err     error
+}
+
+// server polls the checks against the instances and serves their latest results.
+type server struct {
+	instances []serveInstance
+	checks    []serveCheck
+
+	mu      sync.RWMutex
+	results map[string]serveResult
+}
+
+// parseServeInstance parses an instance in the form host:port or as base URL.
+func parseServeInstance(spec string, base Config) (serveInstance, error) {
+	if strings.Contains(spec, "://") {
+		base.URL = spec
+
+		_, err := base.BaseURL()
+		if err != nil {
+			return serveInstance{}, err
+		}
+
+		return serveInstance{name: spec, cfg: base}, nil
+	}
+
+	host, port, err := net.SplitHostPort(spec)
+	if err != nil {
+		return serveInstance{}, fmt.Errorf("could not parse instance %s, use host:port: %w", spec, err)
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return serveInstance{}, fmt.Errorf("could not parse instance %s, use host:port: %w", spec, err)
+	}
+
+	base.Hostname = host
+	base.Port = p
+	base.URL = ""
+
+	return serveInstance{name: spec, cfg: base}, nil
+}
+
+// resetFlag resets a flag to its default value.
+func resetFlag(f *pflag.Flag) {
+	if v, ok := f.Value.(pflag.SliceValue); ok {
+		_ = v.Replace([]string{})
+	} else {
+		_ = f.Value.Set(f.DefValue)
+	}
+
+	f.Changed = false
+}
+
+// parseServeCheck parses a check in the form name=subcommand [flags], e.g.
+// "flow=pipeline flow --warning 5 --critical 10". The flags of the subcommand
+// are parsed like on the command line, the global flags are set for the server.
+func parseServeCheck(root *cobra.Command, spec string, base *Config) (serveCheck, error) {
+	name, command, ok := strings.Cut(spec, "=")
+	if !ok || name == "" {
+		return serveCheck{}, fmt.Errorf("could not parse check %s, use name=subcommand [flags]", spec)
+	}
+
+	c, args, err := root.Find(strings.Fields(command))
+	if err != nil {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: %w", spec, err)
+	}
+
+	bind, ok := serveEvaluators[c]
+	if !ok {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: unknown subcommand %s", spec, command)
+	}
+
+	// Only the flags of the subcommand itself, not the global flags
+	fs := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	fs.AddFlagSet(c.LocalFlags())
+	c.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if root.PersistentFlags().Lookup(f.Name) == nil {
+			fs.AddFlag(f)
+		}
+	})
+
+	// Reset the flags for the next check of the same subcommand
+	defer fs.Visit(resetFlag)
+
+	err = fs.Parse(args)
+	if err != nil {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: %w", spec, err)
+	}
+
+	if fs.NArg() > 0 {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: unknown arguments %s", spec, strings.Join(fs.Args(), " "))
+	}
+
+	err = c.ValidateRequiredFlags()
+	if err != nil {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: %w", spec, err)
+	}
+
+	evaluate, err := bind(base)
+	if err != nil {
+		return serveCheck{}, fmt.Errorf("could not parse check %s: %w", spec, err)
+	}
+
+	return serveCheck{name: name, evaluate: evaluate}, nil
+}
+
+// newServer creates a server for the configured instances and checks.
+func newServer(root *cobra.Command, sc ServeConfig, base Config) (*server, error) {
+	if sc.Interval <= 0 {
+		return nil, fmt.Errorf("invalid --interval %s, use a duration greater than 0", sc.Interval)
+	}
+
+	s := &server{
+		results: map[string]serveResult{},
+	}
+
+	if len(sc.Instances) == 0 {
+		name := base.URL
+		if name == "" {
+			name = net.JoinHostPort(trimBrackets(base.Hostname), strconv.Itoa(base.Port))
+		}
+
+		s.instances = append(s.instances, serveInstance{name: name, cfg: base})
+	}
+
+	for _, spec := range sc.Instances {
+		i, err := parseServeInstance(spec, base)
+		if err != nil {
+			return nil, err
+		}
+
+		s.instances = append(s.instances, i)
+	}
+
+	// The client of each instance is reused for all polls, to keep the connections to Logstash
+	for i := range s.instances {
+		c, err := s.instances[i].cfg.NewClient()
+		if err != nil {
+			return nil, fmt.Errorf("could not create client of instance %s: %w", s.instances[i].name, err)
+		}
+
+		s.instances[i].cfg.client = c
+	}
+
+	for _, spec := range sc.Checks {
+		c, err := parseServeCheck(root, spec, &base)
+		if err != nil {
+			return nil, err
+		}
+
+		if slices.ContainsFunc(s.checks, func(other serveCheck) bool { return other.name == c.name }) {
+			return nil, fmt.Errorf("duplicate check %s", c.name)
+		}
+
+		s.checks = append(s.checks, c)
+	}
+
+	if len(s.checks) == 0 {
+		return nil, errors.New("specify at least one --check")
+	}
+
+	return s, nil
+}
+
+// poll evaluates all checks against all instances and stores the results.
+// The samples in the state file are kept per check, since several checks may run the same subcommand.
+func (s *server) poll() {
+	for i := range s.instances {
+		for _, c := range s.checks {
+			s.instances[i].cfg.stateScope = "serve " + c.name
+
+			o, err := c.evaluate(&s.instances[i].cfg)
+			if err == nil {
+				if r := s.instances[i].cfg.certExpiryResult(); r != nil {
+					o.AddSubcheck(r)
+				}
+			}
+
+			s.mu.Lock()
+			s.results[s.instances[i].name+"/"+c.name] = serveResult{overall: o, err: err}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// handleMetrics serves the metrics of the latest results of all checks and instances.
+func (s *server) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	m := newMetricSet()
+
+	s.mu.RLock()
+
+	for _, i := range s.instances {
+		for _, c := range s.checks {
+			r, ok := s.results[i.name+"/"+c.name]
+			if !ok {
+				continue
+			}
+
+			if r.err != nil {
+				state, _ := errorResult(r.err)
+				m.addState(state, i.name, c.name)
+
+				continue
+			}
+
+			m.addOverall(r.overall, i.name)
+			m.addState(r.overall.GetStatus(), i.name, c.name)
+		}
+	}
+
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	_, _ = w.Write([]byte(m.String()))
+}
+
+// handleCheck serves the latest result of a check in the output format of the command line.
+// The instance and the output format can be chosen with the instance and format parameters.
+func (s *server) handleCheck(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	if !slices.ContainsFunc(s.checks, func(c serveCheck) bool { return c.name == name }) {
+		http.Error(w, fmt.Sprintf("unknown check %s", name), http.StatusNotFound)
+		return
+	}
+
+	instance := s.instances[0].name
+	if r.URL.Query().Has("instance") {
+		instance = r.URL.Query().Get("instance")
+	}
+
+	if !slices.ContainsFunc(s.instances, func(i serveInstance) bool { return i.name == instance }) {
+		http.Error(w, fmt.Sprintf("unknown instance %s", instance), http.StatusNotFound)
+		return
+	}
+
+	opts := outputOptions{
+		format:       cliConfig.Output,
+		onlyProblems: cliConfig.OnlyProblems,
+		host:         instance,
+		command:      name,
+	}
+
+	if r.URL.Query().Has("format") {
+		opts.format = r.URL.Query().Get("format")
+	}
+
+	if !slices.Contains(outputFormats, opts.format) {
+		http.Error(w, fmt.Sprintf("invalid output format %s, use %s", opts.format, strings.Join(outputFormats, ", ")), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	res, ok := s.results[instance+"/"+name]
+	s.mu.RUnlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("no result for check %s yet", name), http.StatusServiceUnavailable)
+		return
+	}
+
+	var (
+		state  check.Status
+		output string
+		err    error
+	)
+
+	if res.err != nil {
+		state, _ = errorResult(res.err)
+		output, err = renderError(res.err, opts)
+	} else {
+		state = res.overall.GetStatus()
+		output, err = renderOverall(res.overall, opts)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("X-Check-State", state.String())
+	_, _ = w.Write([]byte(output))
+}
+
+// handler returns the HTTP handler of the server.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("GET /check/{name}", s.handleCheck)
+
+	return mux
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Polls Logstash instances and serves the results via HTTP",
+	Long: `Polls one or more Logstash instances on an interval and serves the results via HTTP.
+The checks are subcommands with their flags, the results are served at /check/<name>
+in the output format of the command line and all perfdata at /metrics in the OpenMetrics format`,
+	Example: `
+	$ check_logstash serve --listen :9700 --instance logstash1:9600 --instance logstash2:9600 \
+		--check health=health \
+		--check 'flow=pipeline flow --warning 5 --critical 10'
+
+	$ curl 'http://localhost:9700/check/flow?instance=logstash2:9600'
+	[OK] - Flow metrics alright
+	\_ [OK] pipeline example
+	    \_ [OK] queue_backpressure_example:0.34
+
+	$ curl 'http://localhost:9700/metrics'
+	# TYPE logstash_pipelines_queue_backpressure gauge
+	logstash_pipelines_queue_backpressure{host="logstash1:9600",pipeline="example"} 0.34
+	...`,
+	Annotations: map[string]string{
+		// The server handles the timeout per request instead of for the whole process
+		"long-running": "true",
+		"utility":      "true",
+	},
+	Run: func(cmd *cobra.Command, _ []string) {
+		base := cliConfig
+		base.Timeout = time.Duration(Timeout) * time.Second
+
+		s, err := newServer(cmd.Root(), cliServeConfig, base)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		l, err := net.Listen("tcp", cliServeConfig.Listen)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		// Poll in the background, the checks are unavailable until the first poll is done
+		go func() {
+			s.poll()
+
+			for range time.Tick(cliServeConfig.Interval) {
+				s.poll()
+			}
+		}()
+
+		srv := &http.Server{
+			Handler:           s.handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		err = srv.Serve(l)
+		if err != nil {
+			check.ExitError(err)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	fs := serveCmd.Flags()
+
+	fs.StringVar(&cliServeConfig.Listen, "listen", ":9700",
+		"Address to listen on for HTTP requests")
+	fs.DurationVar(&cliServeConfig.Interval, "interval", time.Minute,
+		"Interval in which the checks are evaluated")
+	fs.StringArrayVar(&cliServeConfig.Instances, "instance", []string{},
+		"Logstash instance to poll as host:port or base URL, uses --url or --hostname and --port if not given. Can be used multiple times")
+	fs.StringArrayVar(&cliServeConfig.Checks, "check", []string{"health=health"},
+		"Check to evaluate as name=subcommand [flags], e.g. 'flow=pipeline flow --warning 5 --critical 10'. Can be used multiple times")
+
+	fs.SortFlags = false
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/check_logstash/internal/state"
+)
+
+func TestParseServeCheck(t *testing.T) {
+	c, err := parseServeCheck(rootCmd, "flow=pipeline flow --warning 5 --critical 10", &Config{})
+	if err != nil {
+		t.Error("\nActual: ", err, "\nExpected: ", nil)
+	}
+
+	if c.name != "flow" {
+		t.Error("\nActual: ", c.name, "\nExpected: ", "flow")
+	}
+
+	// The flags are reset for the next check
+	if cliPipelineConfig.Warning != "" {
+		t.Error("\nActual: ", cliPipelineConfig.Warning, "\nExpected: ", "")
+	}
+
+	errors := map[string]string{
+		"flow":                              "could not parse check flow, use name=subcommand [flags]",
+		"flow=pipeline flow --warning 5":    `could not parse check flow=pipeline flow --warning 5: required flag(s) "critical" not set`,
+		"flow=pipeline flow --foo":          "could not parse check flow=pipeline flow --foo: unknown flag: --foo",
+		"flow=pipeline flow --hostname x":   "could not parse check flow=pipeline flow --hostname x: unknown flag: --hostname",
+		"serve=serve":                       "could not parse check serve=serve: unknown subcommand serve",
+		"health=health --unreachable-state": "could not parse check health=health --unreachable-state: flag needs an argument: --unreachable-state",
+		"flow=pipeline flow --warning 5 --critical 10 --sort-by foo": "could not parse check flow=pipeline flow --warning 5 --critical 10 --sort-by foo: invalid sort order foo, use name, state or value",
+		"changes=pipeline changes":                                   "could not parse check changes=pipeline changes: checking pipeline changes requires a --state-file",
+		"reload=pipeline reload --failure-delta-warn 1":              "could not parse check reload=pipeline reload --failure-delta-warn 1: --failure-delta-warn requires a --state-file",
+		"latency=pipeline latency -w 1 -c 2 --interval":              "could not parse check latency=pipeline latency -w 1 -c 2 --interval: --interval requires a --state-file",
+	}
+
+	for spec, expected := range errors {
+		_, err := parseServeCheck(rootCmd, spec, &Config{})
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+
+	// The checks that compare with the previous check run require a state file of the server
+	for _, spec := range []string{"changes=pipeline changes", "reload=pipeline reload --failure-delta-warn 1"} {
+		_, err := parseServeCheck(rootCmd, spec, &Config{StateFile: "state.json"})
+		if err != nil {
+			t.Error("\nActual: ", err, "\nExpected: ", nil)
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	ls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	defer ls.Close()
+
+	u, _ := url.Parse(ls.URL)
+
+	s, err := newServer(rootCmd, ServeConfig{
+		Instances: []string{u.Host, "localhost:9999"},
+		Checks:    []string{"health=health"},
+		Interval:  time.Minute,
+	}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+
+		return resp, string(body)
+	}
+
+	resp, _ := get("/check/health")
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Error("\nActual: ", resp.StatusCode, "\nExpected: ", http.StatusServiceUnavailable)
+	}
+
+	s.poll()
+
+	tests := []struct {
+		path     string
+		status   int
+		state    string
+		expected string
+	}{
+		{"/check/health", http.StatusOK, "OK", "[OK] - Logstash is healthy"},
+		{"/check/health?instance=localhost:9999", http.StatusOK, "UNKNOWN", `[UNKNOWN] - Get "http://localhost:9999/`},
+		{"/check/health?format=json", http.StatusOK, "OK", `"summary": "Logstash is healthy"`},
+		{"/check/health?format=foo", http.StatusBadRequest, "", "invalid output format foo, use icinga, json, openmetrics"},
+		{"/check/foo", http.StatusNotFound, "", "unknown check foo"},
+		{"/check/health?instance=foo:1", http.StatusNotFound, "", "unknown instance foo:1"},
+		{"/metrics", http.StatusOK, "", `check_logstash_state{command="health",host="` + u.Host + `"} 0`},
+		{"/metrics", http.StatusOK, "", `check_logstash_state{command="health",host="localhost:9999"} 3`},
+		{"/metrics", http.StatusOK, "", `logstash_jvm_threads_count{host="` + u.Host + `"} 1`},
+	}
+
+	for _, test := range tests {
+		resp, body := get(test.path)
+
+		if resp.StatusCode != test.status {
+			t.Error("\nActual: ", resp.StatusCode, "\nExpected: ", test.status)
+		}
+
+		if state := resp.Header.Get("X-Check-State"); state != test.state {
+			t.Error("\nActual: ", state, "\nExpected: ", test.state)
+		}
+
+		if !strings.Contains(body, test.expected) {
+			t.Error("\nActual: ", body, "\nExpected: ", test.expected)
+		}
+	}
+}
+
+func TestServerInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		_, err := newServer(rootCmd, ServeConfig{Checks: []string{"health=health"}, Interval: interval}, Config{})
+
+		expected := "invalid --interval " + interval.String() + ", use a duration greater than 0"
+		if err == nil || err.Error() != expected {
+			t.Error("\nActual: ", err, "\nExpected: ", expected)
+		}
+	}
+}
+
+func TestServerStateFile(t *testing.T) {
+	ls := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"localhost","version":"7.17.8","pipelines":{"main":{"events":{"out":50,"in":100},"reloads":{"successes":0,"failures":0},"hash":"f","ephemeral_id":"f"}}}`))
+	}))
+	defer ls.Close()
+
+	u, _ := url.Parse(ls.URL)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	s, err := newServer(rootCmd, ServeConfig{
+		Instances: []string{u.Host},
+		Checks: []string{
+			"main=pipeline --inflight-events-warn 100 --inflight-events-crit 200",
+			"all=pipeline --inflight-events-warn 10 --inflight-events-crit 20",
+			"reload=pipeline reload",
+		},
+		Interval: time.Minute,
+	}, Config{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.poll()
+
+	f, err := state.Load(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, command := range []string{"serve main pipeline", "serve all pipeline", "serve reload pipeline reload"} {
+		key := state.Key(u.Hostname(), s.instances[0].cfg.Port, command, "main")
+		if _, ok := f.Samples[key]; !ok {
+			t.Error("\nActual: ", f.Samples, "\nExpected: ", key)
+		}
+	}
+}
+
+func TestServerClient(t *testing.T) {
+	var conns atomic.Int32
+
+	ls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	ls.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ls.Start()
+	defer ls.Close()
+
+	u, _ := url.Parse(ls.URL)
+
+	s, err := newServer(rootCmd, ServeConfig{
+		Instances: []string{u.Host},
+		Checks:    []string{"health=health", "other=health"},
+		Interval:  time.Minute,
+	}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All polls of all checks share the connection of the instance
+	for range 3 {
+		s.poll()
+	}
+
+	if conns.Load() != 1 {
+		t.Error("\nActual: ", conns.Load(), "\nExpected: ", 1)
+	}
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NETWAYS/check_logstash/internal/logstash"
+	"github.com/NETWAYS/check_logstash/internal/state"
+)
+
+// pipelineState wraps the optional state file, which persists the
+// pipeline counters between check runs. A nil pipelineState is valid
+// and behaves as if there was never a previous sample.
+type pipelineState struct {
+	path    string
+	host    string
+	port    int
+	command string
+	file    *state.File
+	// updated holds the samples of this check run, which are merged into the state file
+	updated map[string]state.Sample
+}
+
+// stateFileFlag is a flag that compares with the previous check run.
+type stateFileFlag struct {
+	name string
+	set  bool
+}
+
+// requireStateFile returns an error for the first flag that is set without a --state-file,
+// since the flag would otherwise never alert.
+func requireStateFile(cfg *Config, flags ...stateFileFlag) error {
+	if cfg.StateFile != "" {
+		return nil
+	}
+
+	for _, f := range flags {
+		if f.set {
+			return fmt.Errorf("%s requires a --state-file", f.name)
+		}
+	}
+
+	return nil
+}
+
+// loadPipelineState loads the state file given by --state-file for the command,
+// e.g. "pipeline reload". Returns nil if no state file is configured.
+func loadPipelineState(cfg *Config, command string) (*pipelineState, error) {
+	if cfg.StateFile == "" {
+		return nil, nil
+	}
+
+	f, err := state.Load(cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.stateScope != "" {
+		command = cfg.stateScope + " " + command
+	}
+
+	s := &pipelineState{
+		path:    cfg.StateFile,
+		host:    cfg.Hostname,
+		port:    cfg.Port,
+		command: command,
+		file:    f,
+		updated: map[string]state.Sample{},
+	}
+
+	// With --url the path prefix identifies the instance as well,
+	// e.g. multiple Logstash instances behind the same reverse proxy
+	if cfg.URL != "" {
+		u, err := cfg.BaseURL()
+		if err != nil {
+			return nil, err
+		}
+
+		s.host = u.Hostname() + strings.TrimSuffix(u.Path, "/")
+		s.port = 80
+
+		if u.Scheme == "https" {
+			s.port = 443
+		}
+
+		if u.Port() != "" {
+			s.port, _ = strconv.Atoi(u.Port())
+		}
+	}
+
+	return s, nil
+}
+
+// newPipelineSample creates a sample from the lifetime counters of a pipeline.
+func newPipelineSample(pipe logstash.PipelineStats, now time.Time) state.Sample {
+	counters := map[string]float64{
+		"events.in":                            float64(pipe.Events.In),
+		"events.out":                           float64(pipe.Events.Out),
+		"events.filtered":                      float64(pipe.Events.Filtered),
+		"events.duration_in_millis":            float64(pipe.Events.Duration),
+		"events.queue_push_duration_in_millis": float64(pipe.Events.QueuePushDuration),
+		"reloads.successes":                    float64(pipe.Reloads.Successes),
+		"reloads.failures":                     float64(pipe.Reloads.Failures),
+	}
+
+	for _, plugins := range [][]logstash.Plugin{pipe.Plugins.Inputs, pipe.Plugins.Filters, pipe.Plugins.Outputs} {
+		for _, plugin := range plugins {
+			counters["plugins."+plugin.ID+".events.in"] = float64(plugin.Events.In)
+			counters["plugins."+plugin.ID+".events.out"] = float64(plugin.Events.Out)
+			counters["plugins."+plugin.ID+".events.duration_in_millis"] = float64(plugin.Events.Duration)
+		}
+	}
+
+	return state.Sample{
+		Timestamp:   now,
+		EphemeralID: pipe.EphemeralID,
+		Hash:        pipe.Hash,
+		Counters:    counters,
+	}
+}
+
+// Previous returns the sample of the pipeline from the previous check run.
+func (s *pipelineState) Previous(pipeline string, current state.Sample) (state.Sample, bool) {
+	if s == nil {
+		return state.Sample{}, false
+	}
+
+	return s.file.Previous(state.Key(s.host, s.port, s.command, pipeline), current)
+}
+
+// Last returns the last stored sample of the pipeline, even if the pipeline restarted since.
+func (s *pipelineState) Last(pipeline string) (state.Sample, bool) {
+	if s == nil {
+		return state.Sample{}, false
+	}
+
+	return s.file.Last(state.Key(s.host, s.port, s.command, pipeline))
+}
+
+// Update stores the current sample of the pipeline for the next check run.
+func (s *pipelineState) Update(pipeline string, current state.Sample) {
+	if s == nil {
+		return
+	}
+
+	key := state.Key(s.host, s.port, s.command, pipeline)
+
+	s.file.Samples[key] = current
+	s.updated[key] = current
+}
+
+// Save merges the samples of this check run into the state file, if one is configured.
+func (s *pipelineState) Save() error {
+	if s == nil {
+		return nil
+	}
+
+	return state.Merge(s.path, s.updated, time.Now())
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+)
+
+// icinga2CheckResult is the request body of /v1/actions/process-check-result.
+type icinga2CheckResult struct {
+	ExitStatus      int      `json:"exit_status"`
+	PluginOutput    string   `json:"plugin_output"`
+	PerformanceData []string `json:"performance_data,omitempty"`
+	CheckSource     string   `json:"check_source,omitempty"`
+}
+
+// icinga2Response is the response of the Icinga 2 API actions.
+type icinga2Response struct {
+	Results []struct {
+		Code   float64 `json:"code"`
+		Status string  `json:"status"`
+	} `json:"results"`
+}
+
+// submitIcinga2 sends the result of the check to the Icinga 2 API as a passive check result
+// for the host or, if configured, the service of the host.
+func (c *Config) submitIcinga2(state check.Status, output string, perfdata check.PerfdataList) error {
+	u, err := url.JoinPath(c.Icinga2URL, "/v1/actions/process-check-result")
+	if err != nil {
+		return err
+	}
+
+	// The object is selected with the host or service parameter, e.g. service=host!service
+	host := c.Icinga2Host
+	if host == "" {
+		host = c.targetHost()
+	}
+
+	q := url.Values{}
+	if c.Icinga2Service != "" {
+		q.Set("service", host+"!"+c.Icinga2Service)
+	} else {
+		q.Set("host", host)
+	}
+
+	body := icinga2CheckResult{
+		ExitStatus:   int(state),
+		PluginOutput: output,
+	}
+
+	for _, p := range perfdata {
+		pd, errPerfdata := p.ValidatedString()
+		if errPerfdata == nil {
+			body.PerformanceData = append(body.PerformanceData, pd)
+		}
+	}
+
+	body.CheckSource, _ = os.Hostname()
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	rt, err := c.newTransport()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u+"?"+q.Encode(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.Icinga2User != "" {
+		user, password, ok := strings.Cut(c.Icinga2User, ":")
+		if !ok {
+			return errors.New("specify the user name and password for the Icinga 2 API <user:password>")
+		}
+
+		req.SetBasicAuth(user, password)
+	}
+
+	client := &http.Client{Transport: rt, Timeout: 10 * time.Second}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var r icinga2Response
+		if json.Unmarshal(respBody, &r) == nil && len(r.Results) > 0 {
+			return fmt.Errorf("could not submit the check result - Error: %d %s", resp.StatusCode, r.Results[0].Status)
+		}
+
+		return fmt.Errorf("could not submit the check result - Error: %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// exitResult prints the output and exits with the state. With --submit-icinga2 the result
+// is submitted to the Icinga 2 API as well. A failed submission replaces the output with
+// an Unknown result in the output format, which contains the summary of the check result.
+func exitResult(state check.Status, output, pluginOutput string, perfdata check.PerfdataList) {
+	if cliConfig.Icinga2URL != "" {
+		err := cliConfig.submitIcinga2(state, pluginOutput, perfdata)
+		if err != nil {
+			summary, _, _ := strings.Cut(pluginOutput, "\n")
+
+			output, err = renderError(&checkError{
+				state: check.Unknown,
+				err:   fmt.Errorf("%w, check result: %s", err, summary),
+			}, cliOutputOptions())
+			if err != nil {
+				check.ExitError(err)
+			}
+
+			state = check.Unknown
+		}
+	}
+
+	_, _ = os.Stdout.WriteString(output)
+
+	check.BaseExit(state)
+}
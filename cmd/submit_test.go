@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSubmitIcinga2(t *testing.T) {
+	var (
+		query  url.Values
+		auth   string
+		result icinga2CheckResult
+	)
+
+	// Stand-in for the Logstash API and the Icinga 2 API
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/actions/process-check-result" {
+			if r.Method != http.MethodPost || r.URL.Query().Get("service") == "unknown!logstash" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"results":[{"code":404,"status":"No objects found."}]}`))
+
+				return
+			}
+
+			query = r.URL.Query()
+			user, password, _ := r.BasicAuth()
+			auth = user + ":" + password
+
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &result)
+
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results":[{"code":200,"status":"Successfully processed check result for object 'logstash!health'."}]}`))
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"8.7.1","http_address":"0.0.0.0:9600","id":"123","name":"logstash","ephemeral_id":"123","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"jvm":{"threads":{"count":50,"peak_count":51},"mem":{"heap_used_percent":20,"heap_committed_in_bytes":519045120,"heap_max_in_bytes":519045120,"heap_used_in_bytes":105661664,"non_heap_used_in_bytes":151706520,"non_heap_committed_in_bytes":165036032},"gc":{"collectors":{"old":{"collection_time_in_millis":0,"collection_count":0},"young":{"collection_time_in_millis":0,"collection_count":0}}},"uptime_in_millis":123},"process":{"open_file_descriptors":120,"peak_open_file_descriptors":120,"max_file_descriptors":16384,"mem":{"total_virtual_in_bytes":7188480000},"cpu":{"total_in_millis":32290,"percent":1,"load_average":{"1m":1.4}}},"events":{},"flow":{},"reloads":{"successes":0,"failures":0},"os":{}}`))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--port", u.Port(),
+		"--submit-icinga2", ts.URL, "--icinga2-user", "root:icinga:secret", "--icinga2-service", "health")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[OK] - Logstash is healthy"
+
+	if !strings.HasPrefix(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	if query.Get("service") != "localhost!health" {
+		t.Error("\nActual: ", query.Get("service"), "\nExpected: ", "localhost!health")
+	}
+
+	if auth != "root:icinga:secret" {
+		t.Error("\nActual: ", auth, "\nExpected: ", "root:icinga:secret")
+	}
+
+	if result.ExitStatus != 0 || !strings.HasPrefix(result.PluginOutput, expected) || strings.Contains(result.PluginOutput, "|") {
+		t.Error("\nActual: ", result, "\nExpected: ", expected)
+	}
+
+	if len(result.PerformanceData) == 0 || !strings.HasPrefix(result.PerformanceData[0], "jvm.mem.heap_used_percent=20%") {
+		t.Error("\nActual: ", result.PerformanceData, "\nExpected: ", "jvm.mem.heap_used_percent=20%")
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "health", "--port", u.Port(),
+		"--submit-icinga2", ts.URL, "--icinga2-host", "unknown", "--icinga2-service", "logstash")
+	out, _ = cmd.CombinedOutput()
+
+	actual = string(out)
+	expected = "[UNKNOWN] - could not submit the check result - Error: 404 No objects found., check result: [OK] - Logstash is healthy"
+
+	if !strings.HasPrefix(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	// go run reports the exit code of the check plugin
+	if !strings.Contains(actual, "exit status 3") {
+		t.Error("\nActual: ", actual, "\nExpected: ", "exit status 3")
+	}
+
+	// A failed submission replaces the result with a single JSON document
+	cmd = exec.Command("go", "run", "../main.go", "health", "--port", u.Port(), "--output", "json",
+		"--submit-icinga2", ts.URL, "--icinga2-host", "unknown", "--icinga2-service", "logstash")
+	out, _ = cmd.Output()
+
+	var doc jsonOutput
+
+	err := json.Unmarshal(out, &doc)
+	if err != nil {
+		t.Fatal(err, string(out))
+	}
+
+	if doc.State != "UNKNOWN" || !strings.HasPrefix(doc.Summary, "could not submit the check result") {
+		t.Error("\nActual: ", doc, "\nExpected: ", "UNKNOWN")
+	}
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// tlsVersions are the supported values of --tls-min-version
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion returns the TLS version of --tls-min-version, e.g. 1.2.
+func parseTLSVersion(version string) (uint16, error) {
+	v, ok := tlsVersions[version]
+	if !ok {
+		versions := slices.Sorted(maps.Keys(tlsVersions))
+		return 0, fmt.Errorf("invalid TLS version %s, use %s", version, strings.Join(versions, ", "))
+	}
+
+	return v, nil
+}
+
+// loadPKCS12 loads the client certificate and the chain of intermediate certificates
+// from a PKCS#12 file, which is decrypted with the password.
+func loadPKCS12(path, password string) (tls.Certificate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not read PKCS#12 file: %w", err)
+	}
+
+	key, cert, chain, err := pkcs12.DecodeChain(b, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("could not decode PKCS#12 file %s: %w", path, err)
+	}
+
+	c := tls.Certificate{
+		PrivateKey: key,
+		Leaf:       cert,
+	}
+
+	c.Certificate = append(c.Certificate, cert.Raw)
+	for _, ca := range chain {
+		c.Certificate = append(c.Certificate, ca.Raw)
+	}
+
+	return c, nil
+}
+
+// pkcs12Password returns the password of --pkcs12-password or the content of --pkcs12-password-file.
+func (c *Config) pkcs12Password() (string, error) {
+	if c.PKCS12PasswordFile == "" {
+		return c.PKCS12Password, nil
+	}
+
+	if c.PKCS12Password != "" {
+		return "", errors.New("specify either --pkcs12-password or --pkcs12-password-file")
+	}
+
+	return readSecret(c.PKCS12PasswordFile)
+}
+
+// applyTLSOptions sets the minimum TLS version and the client certificate of the PKCS#12 file.
+func (c *Config) applyTLSOptions(tlsConfig *tls.Config) error {
+	if c.TLSMinVersion != "" {
+		v, err := parseTLSVersion(c.TLSMinVersion)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.MinVersion = v
+	}
+
+	if c.PKCS12File != "" {
+		password, err := c.pkcs12Password()
+		if err != nil {
+			return err
+		}
+
+		cert, err := loadPKCS12(c.PKCS12File, password)
+		if err != nil {
+			return err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return nil
+}
+
+// certExpiryResult returns a subcheck for the expiry of the TLS certificate of the Logstash API,
+// which is Warning within the days of --tls-expiry-warn-days. Returns nil if disabled or without TLS.
+func (c *Config) certExpiryResult() *checkResult {
+	if c.TLSExpiryWarnDays <= 0 || c.certNotAfter.IsZero() {
+		return nil
+	}
+
+	days := time.Until(c.certNotAfter).Hours() / 24
+	warn := &check.Threshold{Lower: float64(c.TLSExpiryWarnDays), Upper: check.PosInf}
+
+	var r *checkResult
+	if days < 0 {
+		r = newStateResult("tls_certificate", check.Warning, "TLS certificate expired on %s", c.certNotAfter.Format(time.DateOnly))
+	} else {
+		r = newThresholdResult("tls_certificate", days, warn, nil, "TLS certificate expires in %d days on %s",
+			int(days), c.certNotAfter.Format(time.DateOnly))
+	}
+
+	r.AddPerfdata(&check.Perfdata{
+		Label: "tls.certificate.expiry_days",
+		Value: days,
+		Warn:  warn})
+
+	return r
+}
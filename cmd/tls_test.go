@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// writePKCS12 writes a PKCS#12 file with a self-signed client certificate for the common name.
+func writePKCS12(t *testing.T, path, commonName, password string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	b, err := pkcs12.Modern.Encode(key, cert, nil, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.WriteFile(path, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := parseTLSVersion("1.3")
+	if err != nil || v != tls.VersionTLS13 {
+		t.Error("\nActual: ", v, err, "\nExpected: ", tls.VersionTLS13)
+	}
+
+	_, err = parseTLSVersion("1.4")
+
+	expected := "invalid TLS version 1.4, use 1.0, 1.1, 1.2, 1.3"
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+}
+
+func TestLoadPKCS12(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.p12")
+	writePKCS12(t, path, "icinga", "secret")
+
+	cert, err := loadPKCS12(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Leaf.Subject.CommonName != "icinga" || len(cert.Certificate) != 1 {
+		t.Error("\nActual: ", cert.Leaf.Subject.CommonName, len(cert.Certificate), "\nExpected: ", "icinga", 1)
+	}
+
+	_, err = loadPKCS12(path, "wrong")
+	if err == nil {
+		t.Error("\nActual: ", err, "\nExpected: ", "could not decode PKCS#12 file")
+	}
+
+	c := Config{PKCS12File: path, PKCS12Password: "secret", CertFile: "client.pem"}
+
+	_, err = c.newTransport()
+
+	expected := "specify either --pkcs12-file or --cert-file and --key-file"
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+}
+
+func TestConfigCertExpiryResult(t *testing.T) {
+	c := Config{TLSExpiryWarnDays: 30}
+
+	if r := c.certExpiryResult(); r != nil {
+		t.Error("\nActual: ", r.message, "\nExpected: ", nil)
+	}
+
+	tests := []struct {
+		notAfter time.Time
+		state    check.Status
+		message  string
+	}{
+		{time.Now().Add(90*24*time.Hour + time.Hour), check.OK, "TLS certificate expires in 90 days"},
+		{time.Now().Add(10*24*time.Hour + time.Hour), check.Warning, "TLS certificate expires in 10 days"},
+		{time.Now().Add(-time.Hour), check.Warning, "TLS certificate expired on"},
+	}
+
+	for _, test := range tests {
+		c.certNotAfter = test.notAfter
+
+		r := c.certExpiryResult()
+		if r.GetStatus() != test.state || !strings.HasPrefix(r.message, test.message) {
+			t.Error("\nActual: ", r.GetStatus(), r.message, "\nExpected: ", test.state, test.message)
+		}
+	}
+}
+
+func TestHealth_TLSOptions(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 || r.TLS.PeerCertificates[0].Subject.CommonName != "icinga" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"host":"logstash","version":"6.8.23","http_address":"0.0.0.0:9600","id":"123","name":"logstash","jvm":{"threads":{"count":1,"peak_count":2},"mem":{},"gc":{},"uptime_in_millis":123},"process":{},"events":{},"pipelines":{"main":{}},"reloads":{"failures":0,"successes":0},"os":{}}`))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+
+	caFile := filepath.Join(dir, "ca.pem")
+	_ = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}), 0600)
+
+	p12File := filepath.Join(dir, "client.p12")
+	writePKCS12(t, p12File, "icinga", "secret")
+
+	// The test certificate is valid for example.com, but not for the address of the server
+	url := strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	cmd := exec.Command("go", "run", "../main.go", "health", "--url", url, "--ca-file", caFile,
+		"--tls-server-name", "example.com", "--tls-min-version", "1.3", "--pkcs12-file", p12File, "--tls-expiry-warn-days", "100000")
+	cmd.Env = append(os.Environ(), "CHECK_LOGSTASH_PKCS12_PASSWORD=secret")
+	out, _ := cmd.CombinedOutput()
+
+	actual := string(out)
+	expected := "[WARNING] TLS certificate expires in"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	cmd = exec.Command("go", "run", "../main.go", "health", "--url", url, "--ca-file", caFile,
+		"--pkcs12-file", p12File, "--pkcs12-password", "secret")
+	out, _ = cmd.CombinedOutput()
+
+	actual = string(out)
+	expected = "certificate is valid for"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
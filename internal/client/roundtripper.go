@@ -0,0 +1,48 @@
+package client
+
+import (
+	"net/http"
+)
+
+type closeIdler interface {
+	CloseIdleConnections()
+}
+
+type headerRoundTripper struct {
+	header http.Header
+	rt     http.RoundTripper
+}
+
+// NewHeaderRoundTripper adds the provided headers to a request
+// unless the header has already been set.
+func NewHeaderRoundTripper(header http.Header, rt http.RoundTripper) http.RoundTripper {
+	return &headerRoundTripper{header, rt}
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for name, values := range rt.header {
+		if len(req.Header.Values(name)) != 0 {
+			continue
+		}
+
+		// The Host header is part of the request itself
+		if name == "Host" {
+			req.Host = values[0]
+			continue
+		}
+
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	return rt.rt.RoundTrip(req)
+}
+
+func (rt *headerRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.rt.(closeIdler); ok {
+		ci.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderRoundTripper(t *testing.T) {
+	var actual *http.Request
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actual = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	header := http.Header{}
+	header.Add("X-Tenant", "eu")
+	header.Add("X-Forwarded-For", "a")
+	header.Add("X-Forwarded-For", "b")
+	header.Add("Authorization", "ApiKey foo")
+	header.Add("Host", "logstash.example")
+
+	c := NewClient(ts.URL, NewHeaderRoundTripper(header, http.DefaultTransport))
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	req.Header.Set("Authorization", "Bearer bar")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if actual.Header.Get("X-Tenant") != "eu" {
+		t.Error("\nActual: ", actual.Header.Get("X-Tenant"), "\nExpected: ", "eu")
+	}
+
+	if v := actual.Header.Values("X-Forwarded-For"); len(v) != 2 {
+		t.Error("\nActual: ", v, "\nExpected: ", []string{"a", "b"})
+	}
+
+	if actual.Header.Get("Authorization") != "Bearer bar" {
+		t.Error("\nActual: ", actual.Header.Get("Authorization"), "\nExpected: ", "Bearer bar")
+	}
+
+	if actual.Host != "logstash.example" {
+		t.Error("\nActual: ", actual.Host, "\nExpected: ", "logstash.example")
+	}
+
+	if req.Header.Get("X-Tenant") != "" {
+		t.Error("\nActual: ", req.Header.Get("X-Tenant"), "\nExpected: ", "")
+	}
+}
@@ -9,35 +9,79 @@ import (
 
 // https://www.elastic.co/guide/en/logstash/current/node-stats-api.html
 
+// Node is the identity of a Logstash node, which is part of every /_node response.
+type Node struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Host        string `json:"host"`
+	HTTPAddress string `json:"http_address"`
+	EphemeralID string `json:"ephemeral_id"`
+	Version     string `json:"version"`
+}
+
 type Pipeline struct {
-	Host      string `json:"host"`
-	Pipelines map[string]struct {
-		Reloads struct {
-			LastSuccessTime string `json:"last_success_timestamp"`
-			LastFailureTime string `json:"last_failure_timestamp"`
-			Successes       int    `json:"successes"`
-			Failures        int    `json:"failures"`
-		} `json:"reloads"`
-		Flow struct {
-			QueueBackpressure FlowMetric `json:"queue_backpressure"`
-			OutputThroughput  FlowMetric `json:"output_throughput"`
-			InputThroughput   FlowMetric `json:"input_throughput"`
-			FilterThroughput  FlowMetric `json:"filter_throughput"`
-		} `json:"flow"`
-		Queue struct {
-			Type                string `json:"type"`
-			EventsCount         int    `json:"events_count"`
-			QueueSizeInBytes    int    `json:"queue_size_in_bytes"`
-			MaxQueueSizeInBytes int    `json:"max_queue_size_in_bytes"`
-		} `json:"queue"`
-		Events struct {
-			Filtered          int `json:"filtered"`
-			Duration          int `json:"duration"`
-			QueuePushDuration int `json:"queue_push_duration_in_millis"`
-			In                int `json:"in"`
-			Out               int `json:"out"`
-		} `json:"events"`
-	} `json:"pipelines"`
+	Host        string                   `json:"host"`
+	EphemeralID string                   `json:"ephemeral_id"`
+	Pipelines   map[string]PipelineStats `json:"pipelines"`
+}
+
+// NodePipelines is the response of /_node/pipelines, the configuration of the running pipelines.
+type NodePipelines struct {
+	Host      string                  `json:"host"`
+	Pipelines map[string]PipelineInfo `json:"pipelines"`
+}
+
+type PipelineInfo struct {
+	EphemeralID string `json:"ephemeral_id"`
+	Workers     int    `json:"workers"`
+	BatchSize   int    `json:"batch_size"`
+	BatchDelay  int    `json:"batch_delay"`
+}
+
+type PipelineStats struct {
+	EphemeralID string `json:"ephemeral_id"`
+	Hash        string `json:"hash"`
+	Reloads     struct {
+		LastSuccessTime string `json:"last_success_timestamp"`
+		LastFailureTime string `json:"last_failure_timestamp"`
+		Successes       int    `json:"successes"`
+		Failures        int    `json:"failures"`
+	} `json:"reloads"`
+	Flow struct {
+		QueueBackpressure FlowMetric `json:"queue_backpressure"`
+		OutputThroughput  FlowMetric `json:"output_throughput"`
+		InputThroughput   FlowMetric `json:"input_throughput"`
+		FilterThroughput  FlowMetric `json:"filter_throughput"`
+	} `json:"flow"`
+	Queue struct {
+		Type                string `json:"type"`
+		EventsCount         int    `json:"events_count"`
+		QueueSizeInBytes    int    `json:"queue_size_in_bytes"`
+		MaxQueueSizeInBytes int    `json:"max_queue_size_in_bytes"`
+	} `json:"queue"`
+	Events struct {
+		Filtered          int `json:"filtered"`
+		Duration          int `json:"duration_in_millis"`
+		QueuePushDuration int `json:"queue_push_duration_in_millis"`
+		In                int `json:"in"`
+		Out               int `json:"out"`
+	} `json:"events"`
+	Plugins struct {
+		Inputs  []Plugin `json:"inputs"`
+		Filters []Plugin `json:"filters"`
+		Outputs []Plugin `json:"outputs"`
+	} `json:"plugins"`
+}
+
+type Plugin struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Events struct {
+		Duration          int `json:"duration_in_millis"`
+		QueuePushDuration int `json:"queue_push_duration_in_millis"`
+		In                int `json:"in"`
+		Out               int `json:"out"`
+	} `json:"events"`
 }
 
 type FlowMetric struct {
@@ -27,7 +27,7 @@ func TestUmarshallPipelineFlow(t *testing.T) {
 
 func TestUmarshallPipeline(t *testing.T) {
 
-	j := `{"host":"foobar","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"ansible-input":{"events":{"filtered":0,"duration_in_millis":0,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`
+	j := `{"host":"foobar","version":"7.17.8","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"ansible-input":{"events":{"filtered":0,"duration_in_millis":250,"queue_push_duration_in_millis":0,"out":50,"in":100},"plugins":{"inputs":[{"id":"b","name":"beats","events":{"queue_push_duration_in_millis":0,"out":0}}],"codecs":[{"id":"plain","name":"plain","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}},{"id":"json","name":"json","decode":{"writes_in":0,"duration_in_millis":0,"out":0},"encode":{"writes_in":0,"duration_in_millis":0}}],"filters":[],"outputs":[{"id":"f","name":"redis","events":{"duration_in_millis":18,"out":50,"in":100}}]},"reloads":{"successes":0,"last_success_timestamp":null,"last_error":null,"last_failure_timestamp":null,"failures":0},"queue":{"type":"memory","events_count":0,"queue_size_in_bytes":0,"max_queue_size_in_bytes":0},"hash":"f","ephemeral_id":"f"}}}`
 
 	var pl Pipeline
 	err := json.Unmarshal([]byte(j), &pl)
@@ -40,6 +40,27 @@ func TestUmarshallPipeline(t *testing.T) {
 		t.Error("\nActual: ", pl.Host, "\nExpected: ", "foobar")
 	}
 
+	if pl.Pipelines["ansible-input"].Events.Duration != 250 {
+		t.Error("\nActual: ", pl.Pipelines["ansible-input"].Events.Duration, "\nExpected: ", "250")
+	}
+
+}
+
+func TestUmarshallNode(t *testing.T) {
+
+	j := `{"host":"foobar","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green"}`
+
+	var n Node
+	err := json.Unmarshal([]byte(j), &n)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if n.Name != "test" || n.ID != "4" || n.HTTPAddress != "127.0.0.1:9600" {
+		t.Error("\nActual: ", n, "\nExpected: ", "test 4 127.0.0.1:9600")
+	}
+
 }
 
 func TestUmarshallStat(t *testing.T) {
@@ -58,3 +79,23 @@ func TestUmarshallStat(t *testing.T) {
 	}
 
 }
+
+func TestUmarshallNodePipelines(t *testing.T) {
+
+	j := `{"host":"foobar","version":"8.7.1","http_address":"127.0.0.1:9600","id":"4","name":"test","ephemeral_id":"5","status":"green","snapshot":false,"pipeline":{"workers":2,"batch_size":125,"batch_delay":50},"pipelines":{"main":{"ephemeral_id":"a","hash":"b","workers":2,"batch_size":125,"batch_delay":50,"config_reload_automatic":false,"config_reload_interval":3000000000,"dead_letter_queue_enabled":false}}}`
+
+	var np NodePipelines
+	err := json.Unmarshal([]byte(j), &np)
+
+	if err != nil {
+		t.Error(err)
+	}
+
+	if np.Pipelines["main"].Workers != 2 {
+		t.Error("\nActual: ", np.Pipelines["main"].Workers, "\nExpected: ", "2")
+	}
+
+	if np.Pipelines["main"].BatchSize != 125 {
+		t.Error("\nActual: ", np.Pipelines["main"].BatchSize, "\nExpected: ", "125")
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package state
+
+import "os"
+
+// lockFile is a no-op on platforms without file locks.
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+// unlockFile is a no-op on platforms without file locks.
+func unlockFile(_ *os.File) error {
+	return nil
+}
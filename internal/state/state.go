@@ -0,0 +1,185 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Sample is a snapshot of the lifetime counters of a single pipeline.
+type Sample struct {
+	Timestamp   time.Time          `json:"timestamp"`
+	EphemeralID string             `json:"ephemeral_id"`
+	Counters    map[string]float64 `json:"counters"`
+	// StuckSince and StuckRuns track for how long the pipeline
+	// received events without sending any out.
+	StuckSince time.Time `json:"stuck_since,omitzero"`
+	StuckRuns  int       `json:"stuck_runs,omitempty"`
+	// Hash and NodeEphemeralID identify the pipeline's configuration
+	// and the Logstash instance it runs on.
+	Hash            string `json:"hash,omitempty"`
+	NodeEphemeralID string `json:"node_ephemeral_id,omitempty"`
+	// ChangedAt, Change and PreviousHash record the last restart
+	// or configuration change of the pipeline.
+	ChangedAt    time.Time `json:"changed_at,omitzero"`
+	Change       string    `json:"change,omitempty"`
+	PreviousHash string    `json:"previous_hash,omitempty"`
+}
+
+// MaxAge is the age after which samples are removed from the state file,
+// e.g. of pipelines or instances that are no longer checked.
+const MaxAge = 7 * 24 * time.Hour
+
+// File holds the samples of the previous check run,
+// keyed by host, port, command and pipeline.
+type File struct {
+	Samples map[string]Sample `json:"samples"`
+}
+
+// Key returns the key of a pipeline's sample in the state file. Each command
+// keeps its own samples, so commands sharing a state file do not overwrite each other.
+func Key(host string, port int, command, pipeline string) string {
+	return host + ":" + strconv.Itoa(port) + "/" + command + "/" + pipeline
+}
+
+// Load reads the state file from the given path,
+// a missing file results in an empty state.
+func Load(path string) (*File, error) {
+	f := &File{
+		Samples: map[string]Sample{},
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+
+	if err != nil {
+		return f, err
+	}
+
+	err = json.Unmarshal(b, f)
+	if err != nil {
+		return f, fmt.Errorf("could not parse state file %s: %w", path, err)
+	}
+
+	if f.Samples == nil {
+		f.Samples = map[string]Sample{}
+	}
+
+	return f, nil
+}
+
+// Save writes the state file to the given path. The file is replaced
+// atomically, so concurrent check runs never read a partial file.
+func (f *File) Save(path string) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Merge writes the samples into the state file at the given path and removes the samples
+// older than MaxAge. The file is locked while it is read and written, so check runs sharing
+// the state file keep the samples of each other.
+func Merge(path string, samples map[string]Sample, now time.Time) error {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+
+	defer lock.Close()
+
+	err = lockFile(lock)
+	if err != nil {
+		return fmt.Errorf("could not lock state file %s: %w", path, err)
+	}
+
+	defer unlockFile(lock) //nolint: errcheck
+
+	f, err := Load(path)
+	if err != nil {
+		return err
+	}
+
+	maps.Copy(f.Samples, samples)
+
+	maps.DeleteFunc(f.Samples, func(_ string, s Sample) bool {
+		return now.Sub(s.Timestamp) > MaxAge
+	})
+
+	return f.Save(path)
+}
+
+// Previous returns the sample stored for the key, if it belongs to the
+// same pipeline instance. Samples of a restarted pipeline (different
+// ephemeral ID) are discarded, since its counters have been reset.
+func (f *File) Previous(key string, current Sample) (Sample, bool) {
+	prev, ok := f.Samples[key]
+	if !ok || prev.EphemeralID != current.EphemeralID {
+		return Sample{}, false
+	}
+
+	return prev, true
+}
+
+// Last returns the sample stored for the key, regardless of the pipeline instance.
+func (f *File) Last(key string) (Sample, bool) {
+	last, ok := f.Samples[key]
+
+	return last, ok
+}
+
+// Delta returns the increase of a counter between two samples.
+// Returns false if the counter is missing or has been reset.
+func Delta(prev, cur Sample, counter string) (float64, bool) {
+	p, okPrev := prev.Counters[counter]
+	c, okCur := cur.Counters[counter]
+
+	if !okPrev || !okCur || c < p {
+		return 0, false
+	}
+
+	return c - p, true
+}
+
+// Rate returns the increase of a counter per second between two samples.
+func Rate(prev, cur Sample, counter string) (float64, bool) {
+	d, ok := Delta(prev, cur, counter)
+	if !ok {
+		return 0, false
+	}
+
+	seconds := cur.Timestamp.Sub(prev.Timestamp).Seconds()
+	if seconds <= 0 {
+		return 0, false
+	}
+
+	return d / seconds, true
+}
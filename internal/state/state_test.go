@@ -0,0 +1,159 @@
+package state
+
+import (
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	f, err := Load(path)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(f.Samples) != 0 {
+		t.Error("\nActual: ", len(f.Samples), "\nExpected: ", 0)
+	}
+
+	f.Samples[Key("localhost", 9600, "pipeline", "main")] = Sample{
+		EphemeralID: "a",
+		Counters:    map[string]float64{"events.in": 10},
+	}
+
+	err = f.Save(path)
+	if err != nil {
+		t.Error(err)
+	}
+
+	f, err = Load(path)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if f.Samples["localhost:9600/pipeline/main"].Counters["events.in"] != 10 {
+		t.Error("\nActual: ", f.Samples["localhost:9600/pipeline/main"].Counters["events.in"], "\nExpected: ", 10)
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	f := &File{
+		Samples: map[string]Sample{
+			"localhost:9600/pipeline/main": {EphemeralID: "a"},
+		},
+	}
+
+	_, ok := f.Previous("localhost:9600/pipeline/main", Sample{EphemeralID: "a"})
+	if !ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", true)
+	}
+
+	_, ok = f.Previous("localhost:9600/pipeline/main", Sample{EphemeralID: "b"})
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+
+	_, ok = f.Previous("localhost:9600/foo", Sample{EphemeralID: "a"})
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+}
+
+func TestDeltaRate(t *testing.T) {
+	now := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	prev := Sample{Timestamp: now, Counters: map[string]float64{"events.out": 100}}
+	cur := Sample{Timestamp: now.Add(10 * time.Second), Counters: map[string]float64{"events.out": 150}}
+
+	d, ok := Delta(prev, cur, "events.out")
+	if !ok || d != 50 {
+		t.Error("\nActual: ", d, "\nExpected: ", 50)
+	}
+
+	r, ok := Rate(prev, cur, "events.out")
+	if !ok || r != 5 {
+		t.Error("\nActual: ", r, "\nExpected: ", 5)
+	}
+
+	_, ok = Delta(cur, prev, "events.out")
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+
+	_, ok = Delta(prev, cur, "events.in")
+	if ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Now()
+
+	f := &File{
+		Samples: map[string]Sample{
+			"localhost:9600/pipeline/main":        {Timestamp: now.Add(-time.Minute), EphemeralID: "a"},
+			"localhost:9600/pipeline reload/main": {Timestamp: now.Add(-time.Minute), EphemeralID: "a"},
+			"localhost:9600/pipeline/removed":     {Timestamp: now.Add(-MaxAge - time.Minute), EphemeralID: "a"},
+		},
+	}
+
+	err := f.Save(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Merge(path, map[string]Sample{"localhost:9600/pipeline/main": {Timestamp: now, EphemeralID: "b"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The samples of other commands are kept, the outdated samples are removed
+	if f.Samples["localhost:9600/pipeline/main"].EphemeralID != "b" {
+		t.Error("\nActual: ", f.Samples["localhost:9600/pipeline/main"].EphemeralID, "\nExpected: ", "b")
+	}
+
+	if _, ok := f.Samples["localhost:9600/pipeline reload/main"]; !ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", true)
+	}
+
+	if _, ok := f.Samples["localhost:9600/pipeline/removed"]; ok {
+		t.Error("\nActual: ", ok, "\nExpected: ", false)
+	}
+}
+
+func TestMergeConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	now := time.Now()
+
+	var wg sync.WaitGroup
+
+	for i := range 20 {
+		wg.Go(func() {
+			err := Merge(path, map[string]Sample{Key("localhost", 9600, "pipeline", strconv.Itoa(i)): {Timestamp: now}}, now)
+			if err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	wg.Wait()
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(f.Samples) != 20 {
+		t.Error("\nActual: ", len(f.Samples), "\nExpected: ", 20)
+	}
+}